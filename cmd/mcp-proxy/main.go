@@ -4,6 +4,7 @@ import (
 	"flag"
 	"fmt"
 	"log"
+	"os"
 
 	"github.com/IAMSamuelRodda/mcp-proxy/internal/config"
 	"github.com/IAMSamuelRodda/mcp-proxy/internal/server"
@@ -12,6 +13,11 @@ import (
 var BuildVersion = "dev"
 
 func main() {
+	// Subcommands come before any flags
+	if len(os.Args) > 1 && os.Args[1] == "validate-hierarchy" {
+		os.Exit(runValidateHierarchy(os.Args[2:]))
+	}
+
 	conf := flag.String("config", "config.json", "path to config file or a http(s) url")
 	port := flag.String("port", "", "port to listen on (overrides config), e.g. '8080' or ':8080'")
 	_ = flag.String("hierarchy", "testdata/mcp_hierarchy", "path to hierarchy directory")
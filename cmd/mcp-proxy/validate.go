@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/IAMSamuelRodda/mcp-proxy/internal/config"
+	"github.com/IAMSamuelRodda/mcp-proxy/internal/hierarchy"
+)
+
+// runValidateHierarchy implements "mcp-proxy validate-hierarchy", returning the exit code
+// 0 means no errors (warnings are fine), 1 means errors were found, 2 means the check couldn't run
+func runValidateHierarchy(args []string) int {
+	fs := flag.NewFlagSet("validate-hierarchy", flag.ExitOnError)
+	conf := fs.String("config", "config.json", "path to config file or a http(s) url")
+	hierarchyPath := fs.String("hierarchy", "", "path to hierarchy directory or bundle (overrides config)")
+	live := fs.Bool("live", false, "start every server and compare its tools with the hierarchy")
+	liveTimeout := fs.Duration("live-timeout", 2*time.Minute, "overall time limit for the live check")
+	jsonOutput := fs.Bool("json", false, "print the report as JSON")
+	verbose := fs.Bool("verbose", false, "keep the proxy's log output")
+	expandEnv := fs.Bool("expand-env", true, "expand environment variables in config file")
+	httpHeaders := fs.String("http-headers", "", "optional HTTP headers for config URL, format: 'Key1:Value1;Key2:Value2'")
+	httpTimeout := fs.Int("http-timeout", 10, "HTTP timeout in seconds when fetching config from URL")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s validate-hierarchy [flags]\n\n", os.Args[0])
+		fmt.Fprintln(fs.Output(), "Checks the hierarchy for parse failures, duplicate keys, unreachable nodes,")
+		fmt.Fprintln(fs.Output(), "tools without a known server and tools without an inputSchema.")
+		fmt.Fprintln(fs.Output(), "\nFlags:")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+
+	// Loading logs every node; the report is the output unless asked otherwise
+	if !*verbose {
+		log.SetOutput(io.Discard)
+	}
+
+	cfg, err := config.Load(*conf, *expandEnv, *httpHeaders, *httpTimeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		return 2
+	}
+	if *hierarchyPath != "" {
+		cfg.McpProxy.HierarchyPath = *hierarchyPath
+	}
+	if cfg.McpProxy.HierarchyPath == "" {
+		fmt.Fprintln(os.Stderr, "No hierarchy to validate: set hierarchyPath in the config or pass -hierarchy")
+		return 2
+	}
+
+	h, err := hierarchy.LoadHierarchy(cfg.McpProxy.HierarchyPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load hierarchy: %v\n", err)
+		return 1
+	}
+
+	registry := hierarchy.NewServerRegistry(cfg.McpServers)
+	defer registry.Close()
+	serverConfigs, envIssues := h.ServerConfigs(cfg.McpProxy.Options, cfg.ExpandEnv)
+	registry.MergeServerConfigs(serverConfigs)
+
+	report := h.Check(registry)
+	report.Add(envIssues...)
+	if *live {
+		ctx, cancel := context.WithTimeout(context.Background(), *liveTimeout)
+		h.CheckLive(ctx, registry, report)
+		cancel()
+	}
+
+	if *jsonOutput {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		_ = encoder.Encode(report)
+	} else {
+		for _, issue := range report.Issues {
+			fmt.Printf("%-7s  %-16s  %s: %s\n", issue.Severity, issue.Kind, issue.Path, issue.Message)
+		}
+		fmt.Printf("%d errors, %d warnings\n", report.Count(hierarchy.SeverityError), report.Count(hierarchy.SeverityWarning))
+	}
+
+	if report.Count(hierarchy.SeverityError) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -7,8 +7,11 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/exec"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/IAMSamuelRodda/mcp-proxy/internal/config"
@@ -32,6 +35,19 @@ type Client struct {
 	lazyTemplates []mcp.ResourceTemplate
 	activateOnce  sync.Once
 	activated     bool
+	listOnce      sync.Once // Fills lazyPrompts, lazyResources and lazyTemplates
+	// Process supervision, stdio servers only
+	exited     chan struct{}      // Closed once the process is gone
+	kill       context.CancelFunc // Kills the process
+	procDone   <-chan struct{}    // Closed once kill was called
+	exitStatus string
+	stderrTail []byte
+	inFlight   atomic.Int32 // Tool calls, resource reads and prompt gets waiting for a response
+	lastDone   atomic.Int64 // Unix nanoseconds when the last of those finished
+	procMu     sync.Mutex
+	closeOnce  sync.Once
+	closeErr   error
+	closed     chan struct{} // Closed by Close, stops the ping task
 }
 
 func NewMCPClient(name string, conf *config.MCPClientConfigV2) (*Client, error) {
@@ -45,16 +61,30 @@ func NewMCPClient(name string, conf *config.MCPClientConfigV2) (*Client, error)
 		for kk, vv := range v.Env {
 			envs = append(envs, fmt.Sprintf("%s=%s", kk, vv))
 		}
-		mcpClient, err := client.NewStdioMCPClient(v.Command, envs, v.Args...)
+		// The process gets its own context so the supervisor can kill it when it hangs
+		procCtx, kill := context.WithCancel(context.Background())
+		commandFunc := func(_ context.Context, command string, env []string, args []string) (*exec.Cmd, error) {
+			cmd := exec.CommandContext(procCtx, command, args...)
+			cmd.Env = append(os.Environ(), env...)
+			return cmd, nil
+		}
+		mcpClient, err := client.NewStdioMCPClientWithOptions(v.Command, envs, v.Args, transport.WithCommandFunc(commandFunc))
 		if err != nil {
+			kill()
 			return nil, err
 		}
 
-		return &Client{
-			name:    name,
-			client:  mcpClient,
-			options: conf.Options,
-		}, nil
+		c := &Client{
+			name:     name,
+			client:   mcpClient,
+			options:  conf.Options,
+			exited:   make(chan struct{}),
+			kill:     kill,
+			procDone: procCtx.Done(),
+			closed:   make(chan struct{}),
+		}
+		go c.watchProcess()
+		return c, nil
 	case *config.SSEMCPClientConfig:
 		var options []transport.ClientOption
 		if len(v.Headers) > 0 {
@@ -70,6 +100,7 @@ func NewMCPClient(name string, conf *config.MCPClientConfigV2) (*Client, error)
 			needManualStart: true,
 			client:          mcpClient,
 			options:         conf.Options,
+			closed:          make(chan struct{}),
 		}, nil
 	case *config.StreamableMCPClientConfig:
 		var options []transport.StreamableHTTPCOption
@@ -89,6 +120,7 @@ func NewMCPClient(name string, conf *config.MCPClientConfigV2) (*Client, error)
 			needManualStart: true,
 			client:          mcpClient,
 			options:         conf.Options,
+			closed:          make(chan struct{}),
 		}, nil
 	}
 	return nil, errors.New("invalid client type")
@@ -125,9 +157,7 @@ func (c *Client) AddToMCPServer(ctx context.Context, clientInfo mcp.Implementati
 		if err != nil {
 			return err
 		}
-		_ = c.storePromptsForLazyLoad(ctx)
-		_ = c.storeResourcesForLazyLoad(ctx)
-		_ = c.storeResourceTemplatesForLazyLoad(ctx)
+		c.LoadPromptsAndResources(ctx)
 
 		// Register the meta-tool for activation
 		c.registerMetaTool()
@@ -307,6 +337,8 @@ func (c *Client) startPingTask(ctx context.Context) {
 		case <-ctx.Done():
 			log.Printf("<%s> Context done, stopping ping", c.name)
 			return
+		case <-c.closed:
+			return
 		case <-ticker.C:
 			if err := c.client.Ping(ctx); err != nil {
 				if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
@@ -520,81 +552,149 @@ func (c *Client) storeToolsForLazyLoad(ctx context.Context) error {
 	return nil
 }
 
+// LoadPromptsAndResources lists the server's prompts, resources and resource templates
+// into the lazy caches, once. Servers without them leave the caches empty
+func (c *Client) LoadPromptsAndResources(ctx context.Context) {
+	c.listOnce.Do(func() {
+		_ = c.storePromptsForLazyLoad(ctx)
+		_ = c.storeResourcesForLazyLoad(ctx)
+		_ = c.storeResourceTemplatesForLazyLoad(ctx)
+	})
+}
+
+// LazyPrompts returns the prompts cached by LoadPromptsAndResources
+func (c *Client) LazyPrompts() []mcp.Prompt {
+	return c.lazyPrompts
+}
+
+// LazyResources returns the resources cached by LoadPromptsAndResources
+func (c *Client) LazyResources() []mcp.Resource {
+	return c.lazyResources
+}
+
+// LazyResourceTemplates returns the resource templates cached by LoadPromptsAndResources
+func (c *Client) LazyResourceTemplates() []mcp.ResourceTemplate {
+	return c.lazyTemplates
+}
+
 // storePromptsForLazyLoad fetches and stores prompts without registering them
 func (c *Client) storePromptsForLazyLoad(ctx context.Context) error {
-	promptsRequest := mcp.ListPromptsRequest{}
+	prompts, err := c.ListAllPrompts(ctx)
+	if err != nil {
+		return err
+	}
+	log.Printf("<%s> Successfully listed %d prompts for lazy loading", c.name, len(prompts))
+	c.lazyPrompts = append(c.lazyPrompts, prompts...)
+	return nil
+}
+
+// storeResourcesForLazyLoad fetches and stores resources without registering them
+func (c *Client) storeResourcesForLazyLoad(ctx context.Context) error {
+	resources, err := c.ListAllResources(ctx)
+	if err != nil {
+		return err
+	}
+	log.Printf("<%s> Successfully listed %d resources for lazy loading", c.name, len(resources))
+	c.lazyResources = append(c.lazyResources, resources...)
+	return nil
+}
+
+// storeResourceTemplatesForLazyLoad fetches and stores resource templates without registering them
+func (c *Client) storeResourceTemplatesForLazyLoad(ctx context.Context) error {
+	resourceTemplates, err := c.ListAllResourceTemplates(ctx)
+	if err != nil {
+		return err
+	}
+	log.Printf("<%s> Successfully listed %d resource templates for lazy loading", c.name, len(resourceTemplates))
+	c.lazyTemplates = append(c.lazyTemplates, resourceTemplates...)
+	return nil
+}
+
+// ListAllTools returns every tool the server exposes, following pagination
+// Unlike the tools added to the proxy, the tool filter isn't applied
+func (c *Client) ListAllTools(ctx context.Context) ([]mcp.Tool, error) {
+	var all []mcp.Tool
+	toolsRequest := mcp.ListToolsRequest{}
 	for {
-		prompts, err := c.client.ListPrompts(ctx, promptsRequest)
+		tools, err := c.client.ListTools(ctx, toolsRequest)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		if len(prompts.Prompts) == 0 {
+		all = append(all, tools.Tools...)
+		if len(tools.Tools) == 0 || tools.NextCursor == "" {
 			break
 		}
-		log.Printf("<%s> Successfully listed %d prompts for lazy loading", c.name, len(prompts.Prompts))
-		for _, prompt := range prompts.Prompts {
-			c.lazyPrompts = append(c.lazyPrompts, prompt)
+		toolsRequest.Params.Cursor = tools.NextCursor
+	}
+	return all, nil
+}
+
+// ListAllPrompts returns every prompt the server exposes, following pagination
+func (c *Client) ListAllPrompts(ctx context.Context) ([]mcp.Prompt, error) {
+	var all []mcp.Prompt
+	promptsRequest := mcp.ListPromptsRequest{}
+	for {
+		prompts, err := c.client.ListPrompts(ctx, promptsRequest)
+		if err != nil {
+			return nil, err
 		}
-		if prompts.NextCursor == "" {
+		all = append(all, prompts.Prompts...)
+		if len(prompts.Prompts) == 0 || prompts.NextCursor == "" {
 			break
 		}
 		promptsRequest.Params.Cursor = prompts.NextCursor
 	}
-	return nil
+	return all, nil
 }
 
-// storeResourcesForLazyLoad fetches and stores resources without registering them
-func (c *Client) storeResourcesForLazyLoad(ctx context.Context) error {
+// ListAllResources returns every resource the server exposes, following pagination
+func (c *Client) ListAllResources(ctx context.Context) ([]mcp.Resource, error) {
+	var all []mcp.Resource
 	resourcesRequest := mcp.ListResourcesRequest{}
 	for {
 		resources, err := c.client.ListResources(ctx, resourcesRequest)
 		if err != nil {
-			return err
-		}
-		if len(resources.Resources) == 0 {
-			break
-		}
-		log.Printf("<%s> Successfully listed %d resources for lazy loading", c.name, len(resources.Resources))
-		for _, resource := range resources.Resources {
-			c.lazyResources = append(c.lazyResources, resource)
+			return nil, err
 		}
-		if resources.NextCursor == "" {
+		all = append(all, resources.Resources...)
+		if len(resources.Resources) == 0 || resources.NextCursor == "" {
 			break
 		}
 		resourcesRequest.Params.Cursor = resources.NextCursor
-
 	}
-	return nil
+	return all, nil
 }
 
-// storeResourceTemplatesForLazyLoad fetches and stores resource templates without registering them
-func (c *Client) storeResourceTemplatesForLazyLoad(ctx context.Context) error {
+// ListAllResourceTemplates returns every resource template the server exposes, following pagination
+func (c *Client) ListAllResourceTemplates(ctx context.Context) ([]mcp.ResourceTemplate, error) {
+	var all []mcp.ResourceTemplate
 	resourceTemplatesRequest := mcp.ListResourceTemplatesRequest{}
 	for {
 		resourceTemplates, err := c.client.ListResourceTemplates(ctx, resourceTemplatesRequest)
 		if err != nil {
-			return err
-		}
-		if len(resourceTemplates.ResourceTemplates) == 0 {
-			break
-		}
-		log.Printf("<%s> Successfully listed %d resource templates for lazy loading", c.name, len(resourceTemplates.ResourceTemplates))
-		for _, resourceTemplate := range resourceTemplates.ResourceTemplates {
-			c.lazyTemplates = append(c.lazyTemplates, resourceTemplate)
+			return nil, err
 		}
-		if resourceTemplates.NextCursor == "" {
+		all = append(all, resourceTemplates.ResourceTemplates...)
+		if len(resourceTemplates.ResourceTemplates) == 0 || resourceTemplates.NextCursor == "" {
 			break
 		}
 		resourceTemplatesRequest.Params.Cursor = resourceTemplates.NextCursor
 	}
-	return nil
+	return all, nil
 }
 
+// Close closes the connection; for a stdio server it waits for the process to exit
+// Safe to call more than once, later calls return the result of the first
 func (c *Client) Close() error {
-	if c.client != nil {
-		return c.client.Close()
-	}
-	return nil
+	c.closeOnce.Do(func() {
+		if c.closed != nil {
+			close(c.closed)
+		}
+		if c.client != nil {
+			c.closeErr = c.client.Close()
+		}
+	})
+	return c.closeErr
 }
 
 // GetClient returns the underlying MCP client
@@ -0,0 +1,203 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/mark3labs/mcp-go/client"
+	"github.com/mark3labs/mcp-go/client/transport"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// stderrTailBytes is how much of a stdio server's stderr is kept to explain its exit
+const stderrTailBytes = 4096
+
+// A server whose stderr ended is pinged every exitPingTimeout, for at most
+// exitCheckTimeout, to find out whether its process is gone
+const (
+	exitPingTimeout  = 250 * time.Millisecond
+	exitCheckTimeout = 5 * time.Second
+)
+
+// watchProcess drains a stdio server's stderr until it ends, then reaps the process
+// and records how it exited. stderr also ends when the client is closed on purpose, or
+// when a server closes it and keeps running, so otherwise a failed ping must confirm
+// the process is gone before the client is closed
+func (c *Client) watchProcess() {
+	defer c.kill()
+
+	if stderr, ok := client.GetStderr(c.client); ok {
+		buf := make([]byte, 1024)
+		for {
+			n, err := stderr.Read(buf)
+			if n > 0 {
+				c.procMu.Lock()
+				c.stderrTail = append(c.stderrTail, buf[:n]...)
+				if len(c.stderrTail) > stderrTailBytes {
+					c.stderrTail = c.stderrTail[len(c.stderrTail)-stderrTailBytes:]
+				}
+				c.procMu.Unlock()
+			}
+			if err != nil {
+				break
+			}
+		}
+	}
+
+	select {
+	case <-c.closed:
+	default:
+		if c.answersPing() {
+			// Still serving without stderr: wait for the client to be closed, or for
+			// the supervisor to kill the process once it stops answering pings
+			select {
+			case <-c.closed:
+			case <-c.procDone:
+			}
+		}
+	}
+
+	err := c.Close()
+	status := "exit status 0"
+	if err != nil {
+		status = err.Error()
+	}
+	c.procMu.Lock()
+	c.exitStatus = status
+	c.procMu.Unlock()
+	close(c.exited)
+}
+
+// answersPing reports whether the server still responds. The first write to a process
+// that just exited can still succeed, so unanswered pings are repeated until one is
+// answered or refused; a server that does neither in time is killed, so closing the
+// client doesn't wait on it
+func (c *Client) answersPing() bool {
+	deadline := time.Now().Add(exitCheckTimeout)
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), exitPingTimeout)
+		err := c.client.Ping(ctx)
+		cancel()
+		switch {
+		case c.ConnectionLost(err):
+			return false
+		case !errors.Is(err, context.DeadlineExceeded):
+			return true // Answered, even if with an error
+		case time.Now().After(deadline):
+			c.kill()
+			return false
+		}
+	}
+}
+
+// Exited is closed once a stdio server's process has exited or the client was closed
+// nil for SSE and streamable servers, which have no process
+func (c *Client) Exited() <-chan struct{} {
+	return c.exited
+}
+
+// ExitStatus describes how a stdio server's process exited, e.g. "exit status 1" or
+// "signal: killed", followed by the last line it wrote to stderr
+func (c *Client) ExitStatus() string {
+	c.procMu.Lock()
+	defer c.procMu.Unlock()
+	lines := bytes.Split(bytes.TrimSpace(c.stderrTail), []byte("\n"))
+	if last := bytes.TrimSpace(lines[len(lines)-1]); len(last) > 0 {
+		return fmt.Sprintf("%s: %s", c.exitStatus, last)
+	}
+	return c.exitStatus
+}
+
+// Kill kills a stdio server's process, used when it stops answering pings
+func (c *Client) Kill() {
+	if c.kill != nil {
+		c.kill()
+	}
+}
+
+// Busy reports whether tool calls, resource reads or prompt gets are waiting for the
+// server to respond
+func (c *Client) Busy() bool {
+	return c.inFlight.Load() > 0
+}
+
+// LastRequestDone is when the last tool call, resource read or prompt get finished,
+// the zero time if none has
+func (c *Client) LastRequestDone() time.Time {
+	if done := c.lastDone.Load(); done != 0 {
+		return time.Unix(0, done)
+	}
+	return time.Time{}
+}
+
+// CallTool calls a tool on the server
+// For a stdio server the call fails as soon as the process exits instead of running out its timeout
+func (c *Client) CallTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	return sendTracked(c, ctx, func(ctx context.Context) (*mcp.CallToolResult, error) {
+		return c.client.CallTool(ctx, request)
+	})
+}
+
+// ReadResource reads a resource from the server, tracked like CallTool
+func (c *Client) ReadResource(ctx context.Context, request mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+	return sendTracked(c, ctx, func(ctx context.Context) (*mcp.ReadResourceResult, error) {
+		return c.client.ReadResource(ctx, request)
+	})
+}
+
+// GetPrompt gets a prompt from the server, tracked like CallTool
+func (c *Client) GetPrompt(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	return sendTracked(c, ctx, func(ctx context.Context) (*mcp.GetPromptResult, error) {
+		return c.client.GetPrompt(ctx, request)
+	})
+}
+
+// sendTracked sends a request counted as in flight, so the server isn't closed while
+// it waits for the response. For a stdio server the request fails as soon as the
+// process exits
+func sendTracked[T any](c *Client, ctx context.Context, send func(ctx context.Context) (T, error)) (T, error) {
+	c.inFlight.Add(1)
+	defer func() {
+		c.lastDone.Store(time.Now().UnixNano())
+		c.inFlight.Add(-1)
+	}()
+	if c.exited == nil {
+		return send(ctx)
+	}
+
+	sendCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-c.exited:
+			cancel()
+		case <-sendCtx.Done():
+		}
+	}()
+
+	result, err := send(sendCtx)
+	if err != nil && ctx.Err() == nil && sendCtx.Err() != nil {
+		var zero T
+		return zero, fmt.Errorf("server process exited during the call: %s", c.ExitStatus())
+	}
+	return result, err
+}
+
+// ConnectionLost reports whether a failed request never reached the server because the
+// connection is gone, so it can be retried on a fresh client
+func (c *Client) ConnectionLost(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return false
+	}
+	if c.exited != nil {
+		// Writing to a process that exited, or whose client was closed, fails up front
+		return errors.Is(err, syscall.EPIPE) || errors.Is(err, os.ErrClosed)
+	}
+	var transportErr *transport.Error
+	return errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET) || errors.As(err, &transportErr)
+}
@@ -62,14 +62,78 @@ type ToolFilterConfig struct {
 	List []string       `json:"list,omitempty"`
 }
 
+type ConfirmFallback string
+
+const (
+	ConfirmFallbackDeny  ConfirmFallback = "deny"
+	ConfirmFallbackAllow ConfirmFallback = "allow"
+)
+
+// ConfirmToolsConfig selects tool calls that need human approval before they run
+// Only the stdio server can ask the user; HTTP servers always apply the fallback,
+// so Load requires them to set it explicitly
+type ConfirmToolsConfig struct {
+	Destructive bool                `json:"destructive,omitempty"` // Confirm tools annotated with destructiveHint
+	Patterns    []string            `json:"patterns,omitempty"`    // Tool path patterns, e.g. "github.*.delete_*"
+	Fallback    ConfirmFallback     `json:"fallback,omitempty"`    // When the client can't be asked: "deny" (default) or "allow"
+	TimeoutMs   optional.Field[int] `json:"timeoutMs,omitempty"`   // How long to wait for an answer before denying the call
+}
+
+// Enabled reports whether any tool calls need confirmation
+func (c *ConfirmToolsConfig) Enabled() bool {
+	return c != nil && (c.Destructive || len(c.Patterns) > 0)
+}
+
 type OptionsV2 struct {
-	PanicIfInvalid    optional.Field[bool] `json:"panicIfInvalid,omitempty"`
-	LogEnabled        optional.Field[bool] `json:"logEnabled,omitempty"`
-	LazyLoad          optional.Field[bool] `json:"lazyLoad,omitempty"`
-	RecursiveLazyLoad optional.Field[bool] `json:"recursiveLazyLoad,omitempty"`
-	PreloadAll        optional.Field[bool] `json:"preloadAll,omitempty"` // Preload all servers in background at startup
-	AuthTokens        []string             `json:"authTokens,omitempty"`
-	ToolFilter        *ToolFilterConfig    `json:"toolFilter,omitempty"`
+	PanicIfInvalid     optional.Field[bool] `json:"panicIfInvalid,omitempty"`
+	LogEnabled         optional.Field[bool] `json:"logEnabled,omitempty"`
+	LazyLoad           optional.Field[bool] `json:"lazyLoad,omitempty"`
+	RecursiveLazyLoad  optional.Field[bool] `json:"recursiveLazyLoad,omitempty"`
+	PreloadAll         optional.Field[bool] `json:"preloadAll,omitempty"`         // Preload all servers in background at startup
+	ValidateArguments  optional.Field[bool] `json:"validateArguments,omitempty"`  // Validate execute_tool arguments against inputSchema (default: true)
+	MaxResultBytes     optional.Field[int]  `json:"maxResultBytes,omitempty"`     // Page tool results larger than this (default: 0, unlimited)
+	InitTimeoutMs      optional.Field[int]  `json:"initTimeoutMs,omitempty"`      // Time allowed to start and initialize a server (default: 5000)
+	CallTimeoutMs      optional.Field[int]  `json:"callTimeoutMs,omitempty"`      // Time allowed for a tool call, resource read or prompt (default: 60000)
+	RetryBaseDelayMs   optional.Field[int]  `json:"retryBaseDelayMs,omitempty"`   // First retry delay of a server that failed to start, doubled per failure (default: 5000)
+	RetryMaxDelayMs    optional.Field[int]  `json:"retryMaxDelayMs,omitempty"`    // Longest retry delay (default: 300000)
+	PingIntervalMs     optional.Field[int]  `json:"pingIntervalMs,omitempty"`     // How often stdio servers are pinged (default: 30000)
+	MaxRestarts        optional.Field[int]  `json:"maxRestarts,omitempty"`        // Restarts of a stdio server allowed within restartWindowMs (default: 5)
+	RestartWindowMs    optional.Field[int]  `json:"restartWindowMs,omitempty"`    // Window of the restart budget (default: 600000)
+	IdleTimeoutMs      optional.Field[int]  `json:"idleTimeoutMs,omitempty"`      // Close a server unused for this long, started again on next use (default: 0, never)
+	KeepWarm           optional.Field[bool] `json:"keepWarm,omitempty"`           // Never close the server for being idle
+	MaxConcurrentCalls optional.Field[int]  `json:"maxConcurrentCalls,omitempty"` // Tool calls the server runs at once, others wait (default: 0, unlimited)
+	MaxQueue           optional.Field[int]  `json:"maxQueue,omitempty"`           // Calls allowed to wait for a slot before "server busy" (default: unlimited)
+	AuthTokens         []string             `json:"authTokens,omitempty"`
+	ToolFilter         *ToolFilterConfig    `json:"toolFilter,omitempty"`
+
+	// Hierarchy hot reload (proxy-level only)
+	WatchHierarchy          optional.Field[bool] `json:"watchHierarchy,omitempty"`          // default: true
+	HierarchyPollIntervalMs optional.Field[int]  `json:"hierarchyPollIntervalMs,omitempty"` // default: 2000
+
+	// Downstream prompts and resources listed at startup (proxy-level only)
+	DiscoverDownstream optional.Field[bool] `json:"discoverDownstream,omitempty"` // default: false
+
+	// Batch execution via execute_tools (proxy-level only)
+	BatchConcurrency optional.Field[int] `json:"batchConcurrency,omitempty"` // default: 4
+
+	// Paged results kept for fetch_result_page (proxy-level only)
+	ResultTTLMs optional.Field[int] `json:"resultTTLMs,omitempty"` // default: 600000
+
+	// Human confirmation via MCP elicitation (proxy-level only)
+	ConfirmTools *ConfirmToolsConfig `json:"confirmTools,omitempty"`
+
+	// Hybrid mode: tool paths registered as first-class MCP tools (proxy-level only)
+	PinnedTools []string `json:"pinnedTools,omitempty"`
+
+	// Usage-driven adaptive pinning of the most called tools (proxy-level only)
+	AdaptivePinCount     optional.Field[int] `json:"adaptivePinCount,omitempty"`     // default: 0 (disabled)
+	AdaptivePinMaxTokens optional.Field[int] `json:"adaptivePinMaxTokens,omitempty"` // default: 2000
+	UsageStatePath       string              `json:"usageStatePath,omitempty"`       // default: <hierarchyPath>.usage.json
+	UsageSaveIntervalMs  optional.Field[int] `json:"usageSaveIntervalMs,omitempty"`  // default: 60000
+
+	// Token budgets and accounting for meta-tool responses (proxy-level only)
+	CharsPerToken    optional.Field[int] `json:"charsPerToken,omitempty"`    // default: 4
+	ListingMaxTokens optional.Field[int] `json:"listingMaxTokens,omitempty"` // default: 0 (unlimited)
 
 	// Secrets provider options (disabled by default)
 	// Provider type: "none" (default), "openbao", "env"
@@ -231,6 +295,7 @@ func ParseMCPClientConfigV2(conf *MCPClientConfigV2) (any, error) {
 type Config struct {
 	McpProxy   *MCPProxyConfigV2             `json:"mcpProxy"`
 	McpServers map[string]*MCPClientConfigV2 `json:"mcpServers"`
+	ExpandEnv  bool                          `json:"-"` // ${VAR} expansion, also applied to hierarchy mcp_server blocks
 }
 
 type FullConfig struct {
@@ -293,6 +358,88 @@ func validateAuthTokens(tokens []string) error {
 	return nil
 }
 
+// validateConfirmTools checks the fallback, and that HTTP servers set it explicitly
+// since they can't send elicitation requests and never ask the user
+func validateConfirmTools(confirmTools *ConfirmToolsConfig, serverType MCPServerType) error {
+	if confirmTools == nil {
+		return nil
+	}
+	switch ConfirmFallback(strings.ToLower(string(confirmTools.Fallback))) {
+	case "", ConfirmFallbackDeny, ConfirmFallbackAllow:
+	default:
+		return fmt.Errorf("fallback must be %q or %q, got %q", ConfirmFallbackDeny, ConfirmFallbackAllow, confirmTools.Fallback)
+	}
+	if confirmTools.TimeoutMs.OrElse(0) < 0 {
+		return fmt.Errorf("timeoutMs must not be negative, got %d", confirmTools.TimeoutMs.OrElse(0))
+	}
+	if confirmTools.Enabled() && serverType != MCPServerTypeStdio && confirmTools.Fallback == "" {
+		return fmt.Errorf("the %s server can't ask the user to confirm tool calls, so every confirmed call uses the fallback; set fallback to %q or %q",
+			serverType, ConfirmFallbackDeny, ConfirmFallbackAllow)
+	}
+	return nil
+}
+
+// InheritProxyOptions fills in per-server options that aren't set from the proxy-level options
+func InheritProxyOptions(clientConfig *MCPClientConfigV2, proxyOptions *OptionsV2) {
+	if clientConfig.Options == nil {
+		clientConfig.Options = &OptionsV2{}
+	}
+	if proxyOptions == nil {
+		return
+	}
+	if clientConfig.Options.AuthTokens == nil {
+		clientConfig.Options.AuthTokens = proxyOptions.AuthTokens
+	}
+	if !clientConfig.Options.PanicIfInvalid.Present() {
+		clientConfig.Options.PanicIfInvalid = proxyOptions.PanicIfInvalid
+	}
+	if !clientConfig.Options.LogEnabled.Present() {
+		clientConfig.Options.LogEnabled = proxyOptions.LogEnabled
+	}
+	if !clientConfig.Options.LazyLoad.Present() {
+		clientConfig.Options.LazyLoad = proxyOptions.LazyLoad
+	}
+	if !clientConfig.Options.ValidateArguments.Present() {
+		clientConfig.Options.ValidateArguments = proxyOptions.ValidateArguments
+	}
+	if !clientConfig.Options.MaxResultBytes.Present() {
+		clientConfig.Options.MaxResultBytes = proxyOptions.MaxResultBytes
+	}
+	if !clientConfig.Options.InitTimeoutMs.Present() {
+		clientConfig.Options.InitTimeoutMs = proxyOptions.InitTimeoutMs
+	}
+	if !clientConfig.Options.CallTimeoutMs.Present() {
+		clientConfig.Options.CallTimeoutMs = proxyOptions.CallTimeoutMs
+	}
+	if !clientConfig.Options.RetryBaseDelayMs.Present() {
+		clientConfig.Options.RetryBaseDelayMs = proxyOptions.RetryBaseDelayMs
+	}
+	if !clientConfig.Options.RetryMaxDelayMs.Present() {
+		clientConfig.Options.RetryMaxDelayMs = proxyOptions.RetryMaxDelayMs
+	}
+	if !clientConfig.Options.PingIntervalMs.Present() {
+		clientConfig.Options.PingIntervalMs = proxyOptions.PingIntervalMs
+	}
+	if !clientConfig.Options.MaxRestarts.Present() {
+		clientConfig.Options.MaxRestarts = proxyOptions.MaxRestarts
+	}
+	if !clientConfig.Options.RestartWindowMs.Present() {
+		clientConfig.Options.RestartWindowMs = proxyOptions.RestartWindowMs
+	}
+	if !clientConfig.Options.IdleTimeoutMs.Present() {
+		clientConfig.Options.IdleTimeoutMs = proxyOptions.IdleTimeoutMs
+	}
+	if !clientConfig.Options.KeepWarm.Present() {
+		clientConfig.Options.KeepWarm = proxyOptions.KeepWarm
+	}
+	if !clientConfig.Options.MaxConcurrentCalls.Present() {
+		clientConfig.Options.MaxConcurrentCalls = proxyOptions.MaxConcurrentCalls
+	}
+	if !clientConfig.Options.MaxQueue.Present() {
+		clientConfig.Options.MaxQueue = proxyOptions.MaxQueue
+	}
+}
+
 func Load(path string, expandEnv bool, httpHeaders string, httpTimeout int) (*Config, error) {
 	pro, err := newConfProvider(path, expandEnv, httpHeaders, httpTimeout)
 	if err != nil {
@@ -311,21 +458,7 @@ func Load(path string, expandEnv bool, httpHeaders string, httpTimeout int) (*Co
 		conf.McpProxy.Options = &OptionsV2{}
 	}
 	for _, clientConfig := range conf.McpServers {
-		if clientConfig.Options == nil {
-			clientConfig.Options = &OptionsV2{}
-		}
-		if clientConfig.Options.AuthTokens == nil {
-			clientConfig.Options.AuthTokens = conf.McpProxy.Options.AuthTokens
-		}
-		if !clientConfig.Options.PanicIfInvalid.Present() {
-			clientConfig.Options.PanicIfInvalid = conf.McpProxy.Options.PanicIfInvalid
-		}
-		if !clientConfig.Options.LogEnabled.Present() {
-			clientConfig.Options.LogEnabled = conf.McpProxy.Options.LogEnabled
-		}
-		if !clientConfig.Options.LazyLoad.Present() {
-			clientConfig.Options.LazyLoad = conf.McpProxy.Options.LazyLoad
-		}
+		InheritProxyOptions(clientConfig, conf.McpProxy.Options)
 	}
 
 	if conf.McpProxy.Type == "" {
@@ -338,9 +471,13 @@ func Load(path string, expandEnv bool, httpHeaders string, httpTimeout int) (*Co
 			return nil, fmt.Errorf("security validation failed: %w", err)
 		}
 	}
+	if err := validateConfirmTools(conf.McpProxy.Options.ConfirmTools, conf.McpProxy.Type); err != nil {
+		return nil, fmt.Errorf("invalid confirmTools: %w", err)
+	}
 
 	return &Config{
 		McpProxy:   conf.McpProxy,
 		McpServers: conf.McpServers,
+		ExpandEnv:  expandEnv,
 	}, nil
 }
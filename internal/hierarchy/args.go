@@ -0,0 +1,151 @@
+package hierarchy
+
+import (
+	"log"
+	"reflect"
+)
+
+// applyToolArguments merges a tool's default_args and fixed_args into the model's
+// arguments and wraps them in 'params' when the schema requires it
+// Defaults only fill in missing arguments, fixed values always win. Both apply to
+// the arguments inside the params wrapper, whether the model wrapped them or not.
+func (h *Hierarchy) applyToolArguments(toolDef *ToolDefinition, arguments map[string]interface{}) map[string]interface{} {
+	if len(toolDef.DefaultArgs) == 0 && len(toolDef.FixedArgs) == 0 {
+		return h.maybeWrapInParams(toolDef, arguments)
+	}
+
+	// Find the map the tool's own arguments live in
+	inner := arguments
+	wrapped := false
+	if requiresParamsWrapper(toolDef.InputSchema) {
+		if params, ok := arguments["params"].(map[string]interface{}); ok {
+			inner = params
+			wrapped = true
+		}
+	}
+
+	// Copy so the caller's map is never modified
+	merged := make(map[string]interface{}, len(inner)+len(toolDef.DefaultArgs)+len(toolDef.FixedArgs))
+	for name, value := range inner {
+		merged[name] = value
+	}
+	for name, value := range toolDef.DefaultArgs {
+		if _, present := merged[name]; !present {
+			merged[name] = value
+		}
+	}
+	for name, value := range toolDef.FixedArgs {
+		if existing, present := merged[name]; present && !reflect.DeepEqual(existing, value) {
+			log.Printf("Overriding argument %s with its fixed value", name)
+		}
+		merged[name] = value
+	}
+
+	if wrapped {
+		outer := make(map[string]interface{}, len(arguments))
+		for name, value := range arguments {
+			outer[name] = value
+		}
+		outer["params"] = merged
+		return outer
+	}
+	return h.maybeWrapInParams(toolDef, merged)
+}
+
+// VisibleInputSchema returns the inputSchema as the model should see it
+// Fixed arguments are removed entirely and defaulted arguments become optional,
+// with their default value shown. The stored schema is never modified.
+func (t *ToolDefinition) VisibleInputSchema() map[string]interface{} {
+	if t.InputSchema == nil || (len(t.FixedArgs) == 0 && len(t.DefaultArgs) == 0) {
+		return t.InputSchema
+	}
+
+	schema := copySchemaLevel(t.InputSchema)
+	target := schema
+
+	// For a params wrapper, the arguments are properties of params
+	if requiresParamsWrapper(t.InputSchema) {
+		props := schema["properties"].(map[string]interface{})
+		if params, ok := props["params"].(map[string]interface{}); ok {
+			target = copySchemaLevel(params)
+			props["params"] = target
+		}
+	}
+
+	props, _ := target["properties"].(map[string]interface{})
+	for name := range t.FixedArgs {
+		delete(props, name)
+	}
+	for name, value := range t.DefaultArgs {
+		if prop, ok := props[name].(map[string]interface{}); ok {
+			withDefault := make(map[string]interface{}, len(prop)+1)
+			for k, v := range prop {
+				withDefault[k] = v
+			}
+			withDefault["default"] = value
+			props[name] = withDefault
+		}
+	}
+
+	if required, ok := target["required"].([]interface{}); ok {
+		remaining := make([]interface{}, 0, len(required))
+		for _, r := range required {
+			name, _ := r.(string)
+			_, fixed := t.FixedArgs[name]
+			_, defaulted := t.DefaultArgs[name]
+			if !fixed && !defaulted {
+				remaining = append(remaining, r)
+			}
+		}
+		target["required"] = remaining
+	}
+
+	return schema
+}
+
+// copySchemaLevel copies a schema object and its properties map, one level deep
+func copySchemaLevel(schema map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(schema))
+	for k, v := range schema {
+		copied[k] = v
+	}
+	if props, ok := schema["properties"].(map[string]interface{}); ok {
+		copiedProps := make(map[string]interface{}, len(props))
+		for k, v := range props {
+			copiedProps[k] = v
+		}
+		copied["properties"] = copiedProps
+	}
+	return copied
+}
+
+// requiresParamsWrapper reports whether a schema expects all arguments inside a
+// required 'params' object, as Python MCP servers using Pydantic models do
+func requiresParamsWrapper(schema map[string]interface{}) bool {
+	if schema == nil {
+		return false
+	}
+
+	requiredList, ok := schema["required"].([]interface{})
+	if !ok {
+		return false
+	}
+
+	paramsRequired := false
+	for _, r := range requiredList {
+		if str, ok := r.(string); ok && str == "params" {
+			paramsRequired = true
+			break
+		}
+	}
+	if !paramsRequired {
+		return false
+	}
+
+	propsMap, ok := schema["properties"].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	_, hasParamsProp := propsMap["params"]
+	return hasParamsProp
+}
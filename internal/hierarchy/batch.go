@@ -0,0 +1,114 @@
+package hierarchy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Default concurrency and maximum size of an execute_tools batch
+const (
+	DefaultBatchConcurrency = 4
+	MaxBatchSize            = 50
+)
+
+// BatchCall is a single entry of an execute_tools batch
+type BatchCall struct {
+	ToolPath  string                 `json:"tool_path"`
+	Arguments map[string]interface{} `json:"arguments"`
+}
+
+// BatchCallResult reports the outcome of one batch entry
+// Error is set when the call failed before or during execution; a tool that ran
+// but reported isError is also counted as a failure, with its result attached
+type BatchCallResult struct {
+	Index    int                 `json:"index"`
+	ToolPath string              `json:"tool_path"`
+	Success  bool                `json:"success"`
+	Result   *mcp.CallToolResult `json:"result,omitempty"`
+	Error    string              `json:"error,omitempty"`
+}
+
+// HandleExecuteTools handles the execute_tools meta-tool
+// Runs every call through HandleExecuteTool with at most concurrency calls in flight,
+// returning results in the order of the calls. One failing call doesn't affect the others.
+func (h *Hierarchy) HandleExecuteTools(ctx context.Context, registry *ServerRegistry, calls []BatchCall, concurrency int) (map[string]interface{}, error) {
+	if len(calls) == 0 {
+		return nil, fmt.Errorf("calls must contain at least one entry")
+	}
+	if len(calls) > MaxBatchSize {
+		return nil, fmt.Errorf("too many calls in batch: %d (max %d)", len(calls), MaxBatchSize)
+	}
+	if concurrency <= 0 {
+		concurrency = DefaultBatchConcurrency
+	}
+
+	start := time.Now()
+	results := make([]BatchCallResult, len(calls))
+	slots := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+
+	for i, call := range calls {
+		results[i] = BatchCallResult{Index: i, ToolPath: call.ToolPath}
+
+		// Wait for a free slot, giving up on the remaining calls if the request is cancelled
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			results[i].Error = ctx.Err().Error()
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, call BatchCall) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			results[i] = h.executeBatchCall(ctx, registry, i, call)
+		}(i, call)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, result := range results {
+		if result.Success {
+			succeeded++
+		}
+	}
+	log.Printf("Batch of %d calls completed in %v (%d succeeded, %d failed)",
+		len(calls), time.Since(start), succeeded, len(calls)-succeeded)
+
+	return map[string]interface{}{
+		"results":   results,
+		"succeeded": succeeded,
+		"failed":    len(calls) - succeeded,
+	}, nil
+}
+
+// executeBatchCall runs a single batch entry and converts its outcome into a BatchCallResult
+func (h *Hierarchy) executeBatchCall(ctx context.Context, registry *ServerRegistry, index int, call BatchCall) BatchCallResult {
+	result := BatchCallResult{Index: index, ToolPath: call.ToolPath}
+
+	if call.ToolPath == "" {
+		result.Error = "tool_path is required"
+		return result
+	}
+
+	arguments := call.Arguments
+	if arguments == nil {
+		arguments = make(map[string]interface{})
+	}
+
+	callResult, err := h.HandleExecuteTool(ctx, registry, call.ToolPath, arguments)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	result.Result = callResult
+	result.Success = callResult != nil && !callResult.IsError
+	return result
+}
@@ -0,0 +1,163 @@
+package hierarchy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/IAMSamuelRodda/mcp-proxy/internal/config"
+	"github.com/IAMSamuelRodda/mcp-proxy/internal/secrets"
+)
+
+// Default backoff between probes of a server that failed to start
+const (
+	DefaultRetryBaseDelayMs = 5000
+	DefaultRetryMaxDelayMs  = 300000
+)
+
+// BreakerState is the circuit breaker state of a server
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"    // Server is usable
+	BreakerOpen     BreakerState = "open"      // Server is disabled until the next retry
+	BreakerHalfOpen BreakerState = "half-open" // A probe is starting the server
+)
+
+// serverBreaker is the circuit breaker of a server that failed to start
+// Servers without one are closed; the breaker is dropped once a probe succeeds
+type serverBreaker struct {
+	state     BreakerState
+	reason    string
+	failures  int       // Consecutive failed starts
+	nextRetry time.Time // When the open breaker lets the next probe through
+	timer     *time.Timer
+}
+
+// DisableServer opens the circuit breaker of a server with reason
+// The server is probed again after a backoff that doubles with every failure
+func (r *ServerRegistry) DisableServer(name string, reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tripBreaker(name, reason)
+}
+
+// tripBreaker opens the breaker of a server and schedules the next probe
+// Caller must hold r.mu
+func (r *ServerRegistry) tripBreaker(name string, reason string) {
+	b, exists := r.breakers[name]
+	if !exists {
+		b = &serverBreaker{}
+		r.breakers[name] = b
+	}
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+
+	b.failures++
+	delay := retryDelay(r.serverConfigs[name], b.failures)
+	b.state = BreakerOpen
+	b.reason = reason
+	b.nextRetry = time.Now().Add(delay)
+	b.timer = time.AfterFunc(delay, func() { r.probeServer(name) })
+	log.Printf("Server %s DISABLED: %s (failure %d, next retry in %v)", name, reason, b.failures, delay)
+}
+
+// probeServer tries to start a server whose breaker is open, in the background
+// The outcome moves the breaker through loadServer: closed on success, open again on failure
+func (r *ServerRegistry) probeServer(name string) {
+	r.mu.RLock()
+	b, exists := r.breakers[name]
+	probe := !r.closed && exists && b.state == BreakerOpen
+	r.mu.RUnlock()
+	if !probe {
+		return
+	}
+
+	log.Printf("Probing disabled server %s", name)
+	_, _ = r.GetOrLoadServer(context.Background(), name)
+}
+
+// recordLoadResult moves the breaker of a server after a start attempt
+// Caller must hold r.mu
+func (r *ServerRegistry) recordLoadResult(name string, err error) {
+	b, exists := r.breakers[name]
+	if err == nil {
+		if exists {
+			if b.timer != nil {
+				b.timer.Stop()
+			}
+			delete(r.breakers, name)
+			log.Printf("Server %s RE-ENABLED after %d failed attempts", name, b.failures)
+		}
+		return
+	}
+	if r.closed {
+		return
+	}
+	errorCode, _ := secrets.ParseErrorFromStderr(err.Error())
+	r.tripBreaker(name, string(errorCode))
+}
+
+// disabledError returns a DisabledServerError while the breaker of a server is open
+// Once the retry time has passed the next caller goes through and probes the server
+// Caller must hold r.mu
+func (r *ServerRegistry) disabledError(name string) *DisabledServerError {
+	b, exists := r.breakers[name]
+	if !exists || b.state != BreakerOpen || !time.Now().Before(b.nextRetry) {
+		return nil
+	}
+	return &DisabledServerError{
+		Server:  name,
+		Reason:  b.reason,
+		RetryAt: b.nextRetry,
+		Message: fmt.Sprintf("Server '%s' is disabled: %s. Next retry in %v (at %s). Check logs for details.",
+			name, b.reason, time.Until(b.nextRetry).Round(time.Second), b.nextRetry.Format(time.TimeOnly)),
+	}
+}
+
+// CheckEnabled returns a DisabledServerError if the server's breaker is open
+func (r *ServerRegistry) CheckEnabled(name string) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if err := r.disabledError(name); err != nil {
+		return err
+	}
+	return nil
+}
+
+// BreakerState returns the circuit breaker state of a server and, unless closed,
+// the reason it failed and when it is (or was) retried
+func (r *ServerRegistry) BreakerState(name string) (BreakerState, string, time.Time) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	b, exists := r.breakers[name]
+	if !exists {
+		return BreakerClosed, "", time.Time{}
+	}
+	return b.state, b.reason, b.nextRetry
+}
+
+// retryDelay is the backoff before the next probe after the given number of failures
+func retryDelay(cfg *config.MCPClientConfigV2, failures int) time.Duration {
+	baseMs, maxMs := DefaultRetryBaseDelayMs, DefaultRetryMaxDelayMs
+	if cfg != nil && cfg.Options != nil {
+		if cfg.Options.RetryBaseDelayMs.OrElse(0) > 0 {
+			baseMs = cfg.Options.RetryBaseDelayMs.OrElse(DefaultRetryBaseDelayMs)
+		}
+		if cfg.Options.RetryMaxDelayMs.OrElse(0) > 0 {
+			maxMs = cfg.Options.RetryMaxDelayMs.OrElse(DefaultRetryMaxDelayMs)
+		}
+	}
+
+	delay := time.Duration(baseMs) * time.Millisecond
+	maxDelay := time.Duration(maxMs) * time.Millisecond
+	for i := 1; i < failures && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	return delay
+}
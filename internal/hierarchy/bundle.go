@@ -0,0 +1,101 @@
+package hierarchy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// bundleNode is a category in a hierarchy bundle: a node plus its subcategories
+// The whole tree lives in one file, so no flat or nested file naming rules apply
+type bundleNode struct {
+	HierarchyNodeData
+	Categories map[string]*bundleNode `json:"categories,omitempty"`
+}
+
+// isBundleFile reports whether hierarchyPath is a single-file bundle rather than a directory
+func isBundleFile(hierarchyPath string) bool {
+	info, err := os.Stat(hierarchyPath)
+	if err != nil || info.IsDir() {
+		return false
+	}
+	switch strings.ToLower(filepath.Ext(hierarchyPath)) {
+	case ".json", ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+// loadBundle loads every node of a JSON or YAML bundle file
+// A bundle is parsed as a whole, so any error fails the load
+func (h *Hierarchy) loadBundle(bundlePath string) error {
+	data, err := os.ReadFile(bundlePath)
+	if err != nil {
+		return fmt.Errorf("failed to read hierarchy bundle: %w", err)
+	}
+	if ext := strings.ToLower(filepath.Ext(bundlePath)); ext == ".yaml" || ext == ".yml" {
+		if data, err = yamlToJSON(data); err != nil {
+			return fmt.Errorf("failed to parse hierarchy bundle %s: %w", bundlePath, err)
+		}
+	}
+
+	var root bundleNode
+	if err := json.Unmarshal(data, &root); err != nil {
+		return fmt.Errorf("failed to parse hierarchy bundle %s: %w", bundlePath, err)
+	}
+	if err := h.addBundleNode("", &root); err != nil {
+		return fmt.Errorf("invalid hierarchy bundle %s: %w", bundlePath, err)
+	}
+	h.nodes["/"] = h.nodes[""]
+	for nodePath := range h.nodes {
+		h.files[nodePath] = bundlePath
+	}
+	return nil
+}
+
+// addBundleNode adds a bundle category and, recursively, its subcategories
+func (h *Hierarchy) addBundleNode(nodePath string, b *bundleNode) error {
+	h.nodes[nodePath] = nodeFromData(&b.HierarchyNodeData)
+
+	names := make([]string, 0, len(b.Categories))
+	for name := range b.Categories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if name == "" || strings.Contains(name, ".") {
+			return fmt.Errorf("category name %q under %q must be non-empty and contain no dots", name, nodePath)
+		}
+		child := b.Categories[name]
+		if child == nil {
+			child = &bundleNode{}
+		}
+		childPath := name
+		if nodePath != "" {
+			childPath = nodePath + "." + name
+		}
+		if err := h.addBundleNode(childPath, child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// yamlToJSON converts a YAML document to JSON, so YAML bundles parse exactly like
+// JSON ones (numbers become float64, as the tool fields expect)
+func yamlToJSON(data []byte) ([]byte, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		doc = map[string]interface{}{}
+	}
+	return json.Marshal(doc)
+}
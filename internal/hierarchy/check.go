@@ -0,0 +1,274 @@
+package hierarchy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Issue severities: errors break tool calls, warnings are worth a look
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Issue kinds reported by Check and CheckLive
+const (
+	IssueParseError      = "parse_error"      // A node file failed to parse and was skipped
+	IssueDuplicateKey    = "duplicate_key"    // Two definitions share a node path, tool path or JSON key
+	IssueUnreachableNode = "unreachable_node" // A node has no parent category, so browsing never reaches it
+	IssueNoServer        = "no_server"        // A tool has no server field
+	IssueDanglingServer  = "dangling_server"  // A tool's server is neither in mcpServers nor in an mcp_server block
+	IssueNoSchema        = "no_schema"        // A tool has no inputSchema, so arguments can't be validated
+	IssueUnsetVariable   = "unset_variable"   // An mcp_server block uses an environment variable that isn't set
+	IssueServerFailed    = "server_failed"    // A server could not be started or listed (live check)
+	IssueMissingTool     = "missing_tool"     // The server has no tool for a hierarchy tool's maps_to (live check)
+	IssueExtraTool       = "extra_tool"       // The server has a tool no hierarchy tool maps to (live check)
+)
+
+// CheckIssue is a problem found in the hierarchy
+type CheckIssue struct {
+	Severity string `json:"severity"`
+	Kind     string `json:"kind"`
+	Path     string `json:"path"` // Node path, tool path, server name or file, depending on the kind
+	Message  string `json:"message"`
+}
+
+// CheckReport collects the issues found by Check and CheckLive
+type CheckReport struct {
+	Issues []CheckIssue `json:"issues"`
+}
+
+func (r *CheckReport) add(severity, kind, path, format string, args ...interface{}) {
+	r.Issues = append(r.Issues, CheckIssue{
+		Severity: severity,
+		Kind:     kind,
+		Path:     path,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// Add appends issues found outside Check, such as unset variables from ServerConfigs
+func (r *CheckReport) Add(issues ...CheckIssue) {
+	r.Issues = append(r.Issues, issues...)
+	r.sort()
+}
+
+// Count returns the number of issues with the given severity
+func (r *CheckReport) Count(severity string) int {
+	count := 0
+	for _, issue := range r.Issues {
+		if issue.Severity == severity {
+			count++
+		}
+	}
+	return count
+}
+
+// sort orders issues by severity, path, kind and message so reports are stable
+func (r *CheckReport) sort() {
+	sort.SliceStable(r.Issues, func(i, j int) bool {
+		a, b := r.Issues[i], r.Issues[j]
+		if a.Severity != b.Severity {
+			return a.Severity == SeverityError
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Message < b.Message
+	})
+}
+
+// Check reports problems in the loaded hierarchy: files that failed to parse,
+// duplicate keys, unreachable nodes, tools without a (known) server and tools
+// without an inputSchema. Servers are known if the registry has a config for them.
+func (h *Hierarchy) Check(registry *ServerRegistry) *CheckReport {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	report := &CheckReport{}
+	report.Issues = append(report.Issues, h.loadIssues...)
+
+	known := make(map[string]bool)
+	for _, name := range registry.GetServerNames() {
+		known[name] = true
+	}
+
+	// Duplicate keys inside a file are silently dropped by the JSON decoder
+	checkedFiles := make(map[string]bool)
+	for _, file := range h.files {
+		if checkedFiles[file] || !strings.EqualFold(filepath.Ext(file), ".json") {
+			continue
+		}
+		checkedFiles[file] = true
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		for _, key := range duplicateJSONKeys(data) {
+			report.add(SeverityError, IssueDuplicateKey, file, "key %s appears more than once, only the last value is used", key)
+		}
+	}
+
+	for nodePath, t := range h.tree {
+		if nodePath == "" || t.parent != nil {
+			continue
+		}
+		parentPath := "root"
+		if idx := strings.LastIndex(nodePath, "."); idx != -1 {
+			parentPath = nodePath[:idx]
+		}
+		report.add(SeverityWarning, IssueUnreachableNode, nodePath,
+			"parent category %s doesn't exist, the node is only reachable by full tool path", parentPath)
+	}
+
+	for _, entries := range h.toolIndex {
+		byPath := make(map[string]int)
+		for _, entry := range entries {
+			byPath[entry.path]++
+		}
+		for toolPath, count := range byPath {
+			if count > 1 {
+				report.add(SeverityError, IssueDuplicateKey, toolPath, "%d tools share this path, only one of them can be called", count)
+			}
+		}
+
+		for _, entry := range entries {
+			switch {
+			case entry.tool.Server == "":
+				report.add(SeverityError, IssueNoServer, entry.path, "tool has no server")
+			case !known[entry.tool.Server]:
+				report.add(SeverityError, IssueDanglingServer, entry.path,
+					"server %s is not in mcpServers or any mcp_server block", entry.tool.Server)
+			}
+			if entry.tool.InputSchema == nil {
+				report.add(SeverityWarning, IssueNoSchema, entry.path, "tool has no inputSchema")
+			}
+		}
+	}
+
+	report.sort()
+	return report
+}
+
+// CheckLive starts every server the registry knows and compares its tools with the
+// hierarchy: a tool whose maps_to the server doesn't have is missing, a server tool
+// that no hierarchy tool maps to is extra. Issues are added to report.
+func (h *Hierarchy) CheckLive(ctx context.Context, registry *ServerRegistry, report *CheckReport) {
+	// Server name -> tool name on the server -> hierarchy paths mapping to it
+	expected := make(map[string]map[string][]string)
+	h.mu.RLock()
+	for _, entries := range h.toolIndex {
+		for _, entry := range entries {
+			if entry.tool.Server == "" {
+				continue
+			}
+			if expected[entry.tool.Server] == nil {
+				expected[entry.tool.Server] = make(map[string][]string)
+			}
+			name := entry.tool.MapsTo
+			if name == "" {
+				name = entry.path[strings.LastIndex(entry.path, ".")+1:]
+			}
+			expected[entry.tool.Server][name] = append(expected[entry.tool.Server][name], entry.path)
+		}
+	}
+	h.mu.RUnlock()
+
+	serverNames := registry.GetServerNames()
+	sort.Strings(serverNames)
+	for _, serverName := range serverNames {
+		if disabled, reason := registry.IsDisabled(serverName); disabled {
+			report.add(SeverityWarning, IssueServerFailed, serverName, "server is disabled: %s", reason)
+			continue
+		}
+
+		c, err := registry.GetOrLoadServer(ctx, serverName)
+		if err != nil {
+			report.add(SeverityError, IssueServerFailed, serverName, "%v", err)
+			continue
+		}
+		listCtx, cancel := context.WithTimeout(ctx, registry.CallTimeout(serverName))
+		tools, err := c.ListAllTools(listCtx)
+		cancel()
+		if err != nil {
+			report.add(SeverityError, IssueServerFailed, serverName, "failed to list tools: %v", err)
+			continue
+		}
+
+		available := make(map[string]bool, len(tools))
+		for _, tool := range tools {
+			available[tool.Name] = true
+			if _, ok := expected[serverName][tool.Name]; !ok {
+				report.add(SeverityWarning, IssueExtraTool, serverName, "server tool %s is not in the hierarchy", tool.Name)
+			}
+		}
+		for name, toolPaths := range expected[serverName] {
+			if available[name] {
+				continue
+			}
+			for _, toolPath := range toolPaths {
+				report.add(SeverityError, IssueMissingTool, toolPath, "server %s has no tool %s", serverName, name)
+			}
+		}
+	}
+
+	report.sort()
+}
+
+// duplicateJSONKeys returns the paths of object keys that appear more than once in
+// the same object of a JSON document, e.g. "tools.search"
+func duplicateJSONKeys(data []byte) []string {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	var duplicates []string
+	var walk func(path string) error
+	walk = func(path string) error {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		switch token {
+		case json.Delim('{'):
+			seen := make(map[string]bool)
+			for decoder.More() {
+				keyToken, err := decoder.Token()
+				if err != nil {
+					return err
+				}
+				key, _ := keyToken.(string)
+				keyPath := key
+				if path != "" {
+					keyPath = path + "." + key
+				}
+				if seen[key] {
+					duplicates = append(duplicates, keyPath)
+				}
+				seen[key] = true
+				if err := walk(keyPath); err != nil {
+					return err
+				}
+			}
+			_, err = decoder.Token() // Closing brace
+			return err
+		case json.Delim('['):
+			for i := 0; decoder.More(); i++ {
+				if err := walk(fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+			_, err = decoder.Token() // Closing bracket
+			return err
+		}
+		return nil
+	}
+	_ = walk("") // Parse errors are reported when the file is loaded
+	return duplicates
+}
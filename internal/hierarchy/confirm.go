@@ -0,0 +1,155 @@
+package hierarchy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+	"time"
+)
+
+// DefaultConfirmTimeoutMs is how long a confirmation waits for the user by default
+const DefaultConfirmTimeoutMs = 120000
+
+// ErrConfirmationUnsupported is returned by a Confirmer when the client can't be asked
+var ErrConfirmationUnsupported = errors.New("client does not support elicitation")
+
+// ConfirmationRequest describes a tool call waiting for human approval
+type ConfirmationRequest struct {
+	ToolPath  string
+	Server    string
+	Arguments map[string]interface{}
+	Reason    string // Why the call needs approval, e.g. "marked destructive"
+}
+
+// Message is the text shown to the user when asking for approval
+func (r ConfirmationRequest) Message() string {
+	args, err := json.MarshalIndent(r.Arguments, "", "  ")
+	if err != nil || len(r.Arguments) == 0 {
+		args = []byte("{}")
+	}
+	return fmt.Sprintf("Allow the tool %s (%s) to run?\n\nThis tool is %s.\n\nArguments:\n%s",
+		r.ToolPath, r.Server, r.Reason, args)
+}
+
+// Confirmer asks the user of the upstream client to approve a tool call
+// Returns ErrConfirmationUnsupported when the client can't answer such requests
+type Confirmer interface {
+	Confirm(ctx context.Context, request ConfirmationRequest) (bool, error)
+}
+
+// ConfirmationPolicy decides which tool calls need approval and what happens
+// when the client can't be asked
+type ConfirmationPolicy struct {
+	Destructive          bool          // Confirm tools annotated with destructiveHint
+	Patterns             []string      // Tool path patterns; * matches within one path segment
+	AllowWhenUnsupported bool          // Run unconfirmed calls when there is no way to ask
+	Timeout              time.Duration // How long to wait for an answer, 0 waits as long as ctx allows
+	Confirmer            Confirmer
+}
+
+// ConfirmationDeniedError is returned when a tool call was not approved
+type ConfirmationDeniedError struct {
+	ToolPath string
+	Reason   string
+}
+
+func (e *ConfirmationDeniedError) Error() string {
+	return fmt.Sprintf("tool %s was not run: %s", e.ToolPath, e.Reason)
+}
+
+// SetConfirmationPolicy sets the policy HandleExecuteTool applies before running tools
+func (h *Hierarchy) SetConfirmationPolicy(policy *ConfirmationPolicy) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.confirmation = policy
+}
+
+// requiresConfirmation reports whether a tool call needs approval and why
+func (p *ConfirmationPolicy) requiresConfirmation(toolPath string, toolDef *ToolDefinition) (string, bool) {
+	if p == nil {
+		return "", false
+	}
+	if p.Destructive && isDestructive(toolDef) {
+		return "marked destructive", true
+	}
+	for _, pattern := range p.Patterns {
+		if matchToolPath(pattern, toolPath) {
+			return fmt.Sprintf("matched by the confirmation pattern %q", pattern), true
+		}
+	}
+	return "", false
+}
+
+// isDestructive reports whether a tool is annotated as destructive and not read-only
+func isDestructive(toolDef *ToolDefinition) bool {
+	if readOnly, _ := toolDef.Annotations["readOnlyHint"].(bool); readOnly {
+		return false
+	}
+	destructive, _ := toolDef.Annotations["destructiveHint"].(bool)
+	return destructive
+}
+
+// matchToolPath matches a dotted tool path against a pattern such as "github.*.delete_*"
+// Dots separate segments, so * never crosses into the next segment
+func matchToolPath(pattern, toolPath string) bool {
+	matched, err := path.Match(strings.ReplaceAll(pattern, ".", "/"), strings.ReplaceAll(toolPath, ".", "/"))
+	return err == nil && matched
+}
+
+// confirmToolCall asks for approval when the policy requires it
+// toolPath must be the tool's canonical path, so shorthand and aliases can't dodge a pattern
+// Returns a ConfirmationDeniedError unless the call may run
+func (h *Hierarchy) confirmToolCall(ctx context.Context, toolPath, serverName string, toolDef *ToolDefinition, arguments map[string]interface{}) error {
+	h.mu.RLock()
+	policy := h.confirmation
+	h.mu.RUnlock()
+
+	reason, required := policy.requiresConfirmation(toolPath, toolDef)
+	if !required {
+		return nil
+	}
+
+	confirmCtx := ctx
+	if policy.Timeout > 0 {
+		var cancel context.CancelFunc
+		confirmCtx, cancel = context.WithTimeout(ctx, policy.Timeout)
+		defer cancel()
+	}
+
+	err := ErrConfirmationUnsupported
+	approved := false
+	if policy.Confirmer != nil {
+		approved, err = policy.Confirmer.Confirm(confirmCtx, ConfirmationRequest{
+			ToolPath:  toolPath,
+			Server:    serverName,
+			Arguments: arguments,
+			Reason:    reason,
+		})
+	}
+
+	switch {
+	case errors.Is(err, ErrConfirmationUnsupported):
+		if policy.AllowWhenUnsupported {
+			log.Printf("Running %s without confirmation (%s): %v", toolPath, reason, err)
+			return nil
+		}
+		log.Printf("Denied %s, confirmation required (%s): %v", toolPath, reason, err)
+		return &ConfirmationDeniedError{ToolPath: toolPath, Reason: fmt.Sprintf("it is %s and requires confirmation, but the client does not support elicitation", reason)}
+	case errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil:
+		log.Printf("Denied %s, no answer within %v", toolPath, policy.Timeout)
+		return &ConfirmationDeniedError{ToolPath: toolPath, Reason: fmt.Sprintf("the user did not answer within %v", policy.Timeout)}
+	case err != nil:
+		log.Printf("Confirmation of %s failed: %v", toolPath, err)
+		return &ConfirmationDeniedError{ToolPath: toolPath, Reason: fmt.Sprintf("confirmation failed: %v", err)}
+	case !approved:
+		log.Printf("User declined %s", toolPath)
+		return &ConfirmationDeniedError{ToolPath: toolPath, Reason: "the user declined it"}
+	}
+
+	log.Printf("User approved %s", toolPath)
+	return nil
+}
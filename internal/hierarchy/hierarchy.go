@@ -7,8 +7,10 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/IAMSamuelRodda/mcp-proxy/internal/client"
@@ -23,14 +25,37 @@ type HierarchyNode struct {
 	Overview  string                     `json:"overview,omitempty"`
 	Tools     map[string]*ToolDefinition `json:"tools,omitempty"`
 	MCPServer *MCPServerRef              `json:"mcp_server,omitempty"`
+	Aliases   []string                   `json:"aliases,omitempty"`   // Old full paths of this category
+	Redirects map[string]string          `json:"redirects,omitempty"` // Old path (relative to this category) -> new full path
 }
 
 // ToolDefinition represents a tool in the hierarchy
 type ToolDefinition struct {
-	Description string                 `json:"description,omitempty"`
-	MapsTo      string                 `json:"maps_to,omitempty"`
-	Server      string                 `json:"server,omitempty"`
-	InputSchema map[string]interface{} `json:"inputSchema,omitempty"`
+	Title        string                 `json:"title,omitempty"`
+	Description  string                 `json:"description,omitempty"`
+	MapsTo       string                 `json:"maps_to,omitempty"`
+	Server       string                 `json:"server,omitempty"`
+	InputSchema  map[string]interface{} `json:"inputSchema,omitempty"`
+	OutputSchema map[string]interface{} `json:"outputSchema,omitempty"`
+	Annotations  map[string]interface{} `json:"annotations,omitempty"`
+
+	// ValidateArguments overrides the server's validateArguments option for this tool
+	// Set to false for tools whose published inputSchema is known to be wrong
+	ValidateArguments *bool `json:"validate_arguments,omitempty"`
+
+	// Aliases are old full tool paths that still resolve to this tool, e.g. after a move
+	Aliases []string `json:"aliases,omitempty"`
+
+	// FixedArgs are always sent and hidden from the model, DefaultArgs fill in missing arguments
+	// Keys are argument names inside the params wrapper for tools that use one
+	FixedArgs   map[string]interface{} `json:"fixed_args,omitempty"`
+	DefaultArgs map[string]interface{} `json:"default_args,omitempty"`
+
+	// MaxResultBytes overrides the server's maxResultBytes option for this tool (0 keeps it)
+	MaxResultBytes int `json:"max_result_bytes,omitempty"`
+
+	// TimeoutMs overrides the server's callTimeoutMs option for this tool (0 keeps it)
+	TimeoutMs int `json:"timeout_ms,omitempty"`
 }
 
 // HierarchyNodeData is used for unmarshaling JSON with flexible tool types
@@ -38,6 +63,8 @@ type HierarchyNodeData struct {
 	Overview  string                 `json:"overview,omitempty"`
 	Tools     map[string]interface{} `json:"tools,omitempty"`
 	MCPServer *MCPServerRef          `json:"mcp_server,omitempty"`
+	Aliases   []string               `json:"aliases,omitempty"`
+	Redirects map[string]string      `json:"redirects,omitempty"`
 }
 
 // MCPServerRef contains MCP server configuration
@@ -72,6 +99,13 @@ func (m *MCPServerRef) ToClientConfig() *config.MCPClientConfigV2 {
 		cfg.TransportType = config.MCPClientTypeStreamable
 		cfg.URL = m.URL
 		cfg.Headers = m.Headers
+	default:
+		// No type given: a command means stdio, otherwise the URL is used (SSE)
+		cfg.Command = m.Command
+		cfg.Args = m.Args
+		cfg.Env = m.Env
+		cfg.URL = m.URL
+		cfg.Headers = m.Headers
 	}
 
 	return cfg
@@ -79,26 +113,135 @@ func (m *MCPServerRef) ToClientConfig() *config.MCPClientConfigV2 {
 
 // Hierarchy manages the hierarchical tool structure
 type Hierarchy struct {
-	rootPath string
-	nodes    map[string]*HierarchyNode
-	mu       sync.RWMutex
+	rootPath  string
+	nodes     map[string]*HierarchyNode
+	tree      map[string]*treeNode      // node path -> position in the category tree
+	toolIndex map[string][]indexedTool  // bare tool name -> every tool with that name
+	redirects map[string]string         // old tool or category path -> new path
+	servers   map[string]declaredServer // server name -> mcp_server block declaring it
+	results   *ResultStore              // Oversized results for fetch_result_page, kept across reloads
+	files     map[string]string         // node path -> file it was loaded from
+
+	loadIssues []CheckIssue // Nodes skipped or shadowed while loading, reported by Check
+
+	confirmation *ConfirmationPolicy // Which tool calls need human approval, nil for none
+	usage        *UsageStats         // Call counts for adaptive pinning, nil when disabled
+
+	tokens        *TokenTally                      // Running token accounting, kept across reloads
+	listingBudget int                              // Token cap for listings, 0 for none
+	estimator     atomic.Pointer[TokenEstimator]   // nil for the chars/4 default
+	fullExposure  atomic.Pointer[fullExposureCost] // Cached by FullExposureTokens, reset on reload
+	mu            sync.RWMutex
+}
+
+// indexedTool is an entry in the global tool name index
+type indexedTool struct {
+	path string
+	tool *ToolDefinition
+}
+
+// AmbiguousToolError is returned when a bare tool name matches tools in several categories
+type AmbiguousToolError struct {
+	Name       string
+	Candidates []string // Full tool paths, sorted
 }
 
-// LoadHierarchy loads the hierarchy from a directory structure
+func (e *AmbiguousToolError) Error() string {
+	return fmt.Sprintf("tool name '%s' is ambiguous, matches %d tools. Retry with one of these full paths: %s",
+		e.Name, len(e.Candidates), strings.Join(e.Candidates, ", "))
+}
+
+// LoadHierarchy loads the hierarchy from a directory structure or a single bundle file
+// Nodes that fail to parse are skipped with a warning
 func LoadHierarchy(hierarchyPath string) (*Hierarchy, error) {
+	return loadHierarchy(hierarchyPath)
+}
+
+// Reload rebuilds the hierarchy from disk off to the side and swaps it in atomically
+// A file that fails to parse aborts the reload unless it was already broken in the
+// current tree, so a half-written edit never replaces the last good tree while a file
+// that was broken from the start doesn't block reloads of unrelated edits
+func (h *Hierarchy) Reload() error {
+	next, err := loadHierarchy(h.rootPath)
+	if err != nil {
+		return err
+	}
+
+	h.mu.RLock()
+	wasBroken := make(map[string]bool)
+	for _, issue := range h.loadIssues {
+		if issue.Kind == IssueParseError {
+			wasBroken[issue.Path] = true
+		}
+	}
+	h.mu.RUnlock()
+	for _, issue := range next.loadIssues {
+		if issue.Kind != IssueParseError {
+			continue
+		}
+		if !wasBroken[issue.Path] {
+			return fmt.Errorf("failed to load node at %s: %s", issue.Path, issue.Message)
+		}
+		log.Printf("Warning: %s still fails to parse and stays skipped: %s", issue.Path, issue.Message)
+	}
+
+	h.mu.Lock()
+	h.nodes = next.nodes
+	h.tree = next.tree
+	h.toolIndex = next.toolIndex
+	h.redirects = next.redirects
+	h.servers = next.servers
+	h.files = next.files
+	h.loadIssues = next.loadIssues
+	h.mu.Unlock()
+	h.fullExposure.Store(nil)
+
+	log.Printf("Reloaded hierarchy from %s", h.rootPath)
+	return nil
+}
+
+// loadHierarchy loads the hierarchy directory or bundle file and builds a new Hierarchy
+// Nodes that fail to parse are skipped and recorded in loadIssues
+func loadHierarchy(hierarchyPath string) (*Hierarchy, error) {
 	h := &Hierarchy{
 		rootPath: hierarchyPath,
 		nodes:    make(map[string]*HierarchyNode),
+		files:    make(map[string]string),
+		results:  NewResultStore(DefaultResultTTL),
+		tokens:   NewTokenTally(),
 	}
 
+	// hierarchyPath is either a directory of JSON files or a single bundle file
+	if isBundleFile(hierarchyPath) {
+		if err := h.loadBundle(hierarchyPath); err != nil {
+			return nil, err
+		}
+	} else if err := h.loadDirectory(hierarchyPath); err != nil {
+		return nil, err
+	}
+
+	h.collectServers()
+	h.applyToolMappings()
+	h.buildTree()
+	h.buildToolIndex()
+	h.buildRedirects()
+
+	log.Printf("Loaded %d hierarchy nodes (%d unique tool names)", len(h.nodes), len(h.toolIndex))
+	return h, nil
+}
+
+// loadDirectory loads root.json and every node file under a hierarchy directory
+// Node files that fail to parse are skipped with a warning
+func (h *Hierarchy) loadDirectory(hierarchyPath string) error {
 	// Load root.json
 	rootFile := filepath.Join(hierarchyPath, "root.json")
 	rootNode, err := loadNode(rootFile)
 	if err != nil {
-		return nil, fmt.Errorf("failed to load root node: %w", err)
+		return fmt.Errorf("failed to load root node: %w", err)
 	}
 	h.nodes[""] = rootNode
 	h.nodes["/"] = rootNode
+	h.files[""] = rootFile
 
 	// Walk the directory structure and load all nodes
 	err = filepath.Walk(hierarchyPath, func(path string, info os.FileInfo, err error) error {
@@ -147,20 +290,61 @@ func LoadHierarchy(hierarchyPath string) (*Hierarchy, error) {
 		node, err := loadNode(path)
 		if err != nil {
 			log.Printf("Warning: failed to load node at %s: %v", path, err)
+			h.loadIssues = append(h.loadIssues, CheckIssue{
+				Severity: SeverityError,
+				Kind:     IssueParseError,
+				Path:     path,
+				Message:  fmt.Sprintf("failed to parse: %v", err),
+			})
 			return nil // Continue loading other nodes
 		}
 
+		// Flat and nested files can map to the same key, the later file wins
+		if previous, exists := h.files[hierarchyKey]; exists {
+			log.Printf("Warning: hierarchy node %s from %s replaces the one from %s", hierarchyKey, path, previous)
+			h.loadIssues = append(h.loadIssues, CheckIssue{
+				Severity: SeverityError,
+				Kind:     IssueDuplicateKey,
+				Path:     hierarchyKey,
+				Message:  fmt.Sprintf("node is defined by both %s and %s, only %s is used", previous, path, path),
+			})
+		}
+
 		h.nodes[hierarchyKey] = node
+		h.files[hierarchyKey] = path
 		log.Printf("Loaded hierarchy node: %s from %s", hierarchyKey, path)
 		return nil
 	})
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to walk hierarchy: %w", err)
+		return fmt.Errorf("failed to walk hierarchy: %w", err)
 	}
+	return nil
 
-	log.Printf("Loaded %d hierarchy nodes", len(h.nodes))
-	return h, nil
+}
+
+// buildToolIndex indexes every tool by its bare name so ResolveToolPath can
+// resolve "just the tool name" without knowing the category
+func (h *Hierarchy) buildToolIndex() {
+	h.toolIndex = make(map[string][]indexedTool)
+	for nodePath, node := range h.nodes {
+		if nodePath == "/" {
+			continue // Alias of the root node
+		}
+		for toolName, toolDef := range node.Tools {
+			h.toolIndex[toolName] = append(h.toolIndex[toolName], indexedTool{
+				path: toolPathFor(nodePath, toolName),
+				tool: toolDef,
+			})
+		}
+	}
+
+	// Keep candidate order deterministic for error messages
+	for _, entries := range h.toolIndex {
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].path < entries[j].path
+		})
+	}
 }
 
 // loadNode loads a single node from a JSON file
@@ -175,17 +359,26 @@ func loadNode(path string) (*HierarchyNode, error) {
 		return nil, err
 	}
 
-	// Convert to HierarchyNode with typed tools
+	return nodeFromData(&nodeData), nil
+}
+
+// nodeFromData converts a parsed node into a HierarchyNode with typed tools
+func nodeFromData(nodeData *HierarchyNodeData) *HierarchyNode {
 	node := &HierarchyNode{
 		Overview:  nodeData.Overview,
 		Tools:     make(map[string]*ToolDefinition),
 		MCPServer: nodeData.MCPServer,
+		Aliases:   nodeData.Aliases,
+		Redirects: nodeData.Redirects,
 	}
 
 	// Parse tools - can be either map[string]interface{} or direct ToolDefinition
 	for toolName, toolData := range nodeData.Tools {
 		if toolMap, ok := toolData.(map[string]interface{}); ok {
 			tool := &ToolDefinition{}
+			if title, ok := toolMap["title"].(string); ok {
+				tool.Title = title
+			}
 			if desc, ok := toolMap["description"].(string); ok {
 				tool.Description = desc
 			}
@@ -201,11 +394,44 @@ func loadNode(path string) (*HierarchyNode, error) {
 			if schema, ok := toolMap["inputSchema"].(map[string]interface{}); ok {
 				tool.InputSchema = schema
 			}
+			if schema, ok := toolMap["outputSchema"].(map[string]interface{}); ok {
+				tool.OutputSchema = schema
+			}
+			if annotations, ok := toolMap["annotations"].(map[string]interface{}); ok {
+				tool.Annotations = annotations
+			}
+			if validate, ok := toolMap["validate_arguments"].(bool); ok {
+				tool.ValidateArguments = &validate
+			}
+			if maxResultBytes, ok := toolMap["max_result_bytes"].(float64); ok {
+				tool.MaxResultBytes = int(maxResultBytes)
+			}
+			if timeoutMs, ok := toolMap["timeout_ms"].(float64); ok {
+				tool.TimeoutMs = int(timeoutMs)
+			}
+			if fixedArgs, ok := toolMap["fixed_args"].(map[string]interface{}); ok {
+				tool.FixedArgs = fixedArgs
+			}
+			if defaultArgs, ok := toolMap["default_args"].(map[string]interface{}); ok {
+				tool.DefaultArgs = defaultArgs
+			}
+			if aliases, ok := toolMap["aliases"].([]interface{}); ok {
+				for _, alias := range aliases {
+					if aliasStr, ok := alias.(string); ok {
+						tool.Aliases = append(tool.Aliases, aliasStr)
+					}
+				}
+			}
 			node.Tools[toolName] = tool
 		}
 	}
 
-	return node, nil
+	return node
+}
+
+// Results returns the store holding oversized results for fetch_result_page
+func (h *Hierarchy) Results() *ResultStore {
+	return h.results
 }
 
 // GetRootNode returns the root node of the hierarchy
@@ -217,9 +443,18 @@ func (h *Hierarchy) GetRootNode() *HierarchyNode {
 
 // HandleGetToolsInCategory handles the get_tools_in_category meta-tool
 // Returns a map with path, overview, children info, and tools
-func (h *Hierarchy) HandleGetToolsInCategory(path string) (map[string]interface{}, error) {
+// Children come from the precomputed tree, so listing is O(children) and the
+// JSON output (map keys are sorted by encoding/json) is identical across calls
+//
+// depth > 1 expands branch children in place, breadth-first, up to that many levels.
+// maxTokens > 0 caps the estimated size of the response: a child whose expansion
+// would exceed the budget is left collapsed and marked "truncated". The listing
+// budget applies too, and if even one level doesn't fit, entries are cut and
+// counted under "more_available".
+func (h *Hierarchy) HandleGetToolsInCategory(path string, depth int, maxTokens int) (map[string]interface{}, error) {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
+	maxTokens = h.listingBudgetFor(maxTokens)
 
 	// Normalize path
 	if path == "/" {
@@ -227,81 +462,134 @@ func (h *Hierarchy) HandleGetToolsInCategory(path string) (map[string]interface{
 	}
 	path = strings.Trim(path, ".")
 
-	// Find the node
-	node, exists := h.nodes[path]
+	// Find the node, following redirects for categories that were moved
+	requestedPath := path
+	current, exists := h.tree[path]
+	for hops := 0; !exists && hops < maxRedirectHops; hops++ {
+		next, ok := h.followRedirect(path)
+		if !ok {
+			break
+		}
+		path = next
+		current, exists = h.tree[path]
+	}
 	if !exists {
-		return nil, fmt.Errorf("category not found: %s", path)
+		return nil, fmt.Errorf("category not found: %s", requestedPath)
 	}
 
-	// Build response
-	response := map[string]interface{}{
-		"path": path,
+	response := describeCategory(current)
+	response["path"] = path
+	if path != requestedPath {
+		response["deprecation"] = deprecationNote(requestedPath, path)
+	}
+	if maxTokens > 0 {
+		h.trimListing(response, maxTokens)
+	}
+
+	if depth > MaxCategoryDepth {
+		depth = MaxCategoryDepth
+	}
+	if depth <= 1 {
+		return response, nil
+	}
+
+	// Expand branch children level by level, so a tight budget is spent on
+	// the shallow levels before anything deeper
+	type pendingExpansion struct {
+		t     *treeNode
+		info  map[string]interface{}
+		level int
+	}
+	var queue []pendingExpansion
+	enqueueBranches := func(t *treeNode, description map[string]interface{}, level int) {
+		children, _ := description["children"].(map[string]interface{})
+		for _, child := range t.children {
+			if len(child.node.Tools) > 0 {
+				continue // Leaf children are already summarized
+			}
+			if info, ok := children[child.name].(map[string]interface{}); ok {
+				queue = append(queue, pendingExpansion{t: child, info: info, level: level})
+			}
+		}
+	}
+	enqueueBranches(current, response, 2)
+
+	used := h.EstimateTokens(response)
+	truncated := false
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+
+		expanded := describeCategory(next.t)
+		cost := h.EstimateTokens(expanded) - h.EstimateTokens(next.info)
+		if maxTokens > 0 && used+cost > maxTokens {
+			next.info["truncated"] = true
+			truncated = true
+			continue
+		}
+		used += cost
+
+		// Replace the collapsed summary in place so the parent sees the subtree
+		for key, value := range expanded {
+			next.info[key] = value
+		}
+		if next.level < depth {
+			enqueueBranches(next.t, expanded, next.level+1)
+		}
 	}
 
+	if truncated {
+		response["truncated"] = true
+	}
+	return response, nil
+}
+
+// describeCategory builds the one-level description of a category: its overview,
+// direct children and tools. Caller must hold h.mu
+func describeCategory(current *treeNode) map[string]interface{} {
+	node := current.node
+	path := current.path
+
+	// Build response
+	response := map[string]interface{}{}
+
 	if node.Overview != "" {
 		response["overview"] = node.Overview
 	}
 
-	// Find child nodes
+	// Describe direct children
 	children := make(map[string]interface{})
 	allChildrenAreLeaves := true
 	aggregatedTools := make(map[string]interface{})
 
-	for nodePath := range h.nodes {
-		if nodePath == path || nodePath == "" {
-			continue
-		}
-
-		// Check if this node is a direct child of the current path
-		var isDirectChild bool
-		var childName string
-
-		if path == "" {
-			// Root level - direct children have no dots
-			if !strings.Contains(nodePath, ".") {
-				isDirectChild = true
-				childName = nodePath
+	for _, child := range current.children {
+		childNode := child.node
+		if len(childNode.Tools) > 0 {
+			// Leaf node
+			children[child.name] = map[string]interface{}{
+				"is_leaf":    true,
+				"tool_count": len(childNode.Tools),
 			}
-		} else {
-			// Non-root - check if path is a prefix and child is one level deeper
-			if strings.HasPrefix(nodePath, path+".") {
-				remainder := strings.TrimPrefix(nodePath, path+".")
-				if !strings.Contains(remainder, ".") {
-					isDirectChild = true
-					childName = remainder
-				}
-			}
-		}
 
-		if isDirectChild {
-			childNode := h.nodes[nodePath]
-			if len(childNode.Tools) > 0 {
-				// Leaf node
-				children[childName] = map[string]interface{}{
-					"is_leaf":    true,
-					"tool_count": len(childNode.Tools),
+			// Aggregate tools from leaf children
+			// Children and tools are sorted, so on a name clash the first one wins deterministically
+			for _, toolName := range child.toolNames {
+				if _, exists := aggregatedTools[toolName]; exists {
+					continue
 				}
-
-				// Aggregate tools from leaf children
-				for toolName, toolDef := range childNode.Tools {
-					// In flat structure, nodePath already includes the tool name
-					// e.g., "everything.echo" not "everything.echo.echo"
-					toolPath := nodePath
-
-					aggregatedTools[toolName] = map[string]interface{}{
-						"description": toolDef.Description,
-						"tool_path":   toolPath,
-					}
-				}
-			} else {
-				// Branch node
-				allChildrenAreLeaves = false
-				childInfo := map[string]interface{}{}
-				if childNode.Overview != "" {
-					childInfo["overview"] = childNode.Overview
+				aggregatedTools[toolName] = map[string]interface{}{
+					"description": childNode.Tools[toolName].Description,
+					"tool_path":   toolPathFor(child.path, toolName),
 				}
-				children[childName] = childInfo
 			}
+		} else {
+			// Branch node
+			allChildrenAreLeaves = false
+			childInfo := map[string]interface{}{}
+			if childNode.Overview != "" {
+				childInfo["overview"] = childNode.Overview
+			}
+			children[child.name] = childInfo
 		}
 	}
 
@@ -313,17 +601,10 @@ func (h *Hierarchy) HandleGetToolsInCategory(path string) (map[string]interface{
 	if len(node.Tools) > 0 {
 		// Node has direct tools
 		toolsInfo := make(map[string]interface{})
-		for toolName, toolDef := range node.Tools {
-			var toolPath string
-			if path == "" {
-				toolPath = toolName
-			} else {
-				toolPath = path + "." + toolName
-			}
-
+		for _, toolName := range current.toolNames {
 			toolsInfo[toolName] = map[string]interface{}{
-				"description": toolDef.Description,
-				"tool_path":   toolPath,
+				"description": node.Tools[toolName].Description,
+				"tool_path":   toolPathFor(path, toolName),
 			}
 		}
 		response["tools"] = toolsInfo
@@ -334,19 +615,49 @@ func (h *Hierarchy) HandleGetToolsInCategory(path string) (map[string]interface{
 		response["tools"] = make(map[string]interface{})
 	}
 
-	return response, nil
+	return response
 }
 
 // ResolveToolPath resolves a tool path to its definition and server name
 // Returns the tool definition, server name (empty for meta-tools or if not configured), and any error
 func (h *Hierarchy) ResolveToolPath(toolPath string) (*ToolDefinition, string, error) {
+	toolDef, serverName, _, err := h.resolveToolPath(toolPath)
+	return toolDef, serverName, err
+}
+
+// resolveToolPath resolves a tool path, following aliases and redirects when the
+// path doesn't exist (anymore). Also returns the path the tool was finally found at,
+// which differs from toolPath when a redirect was followed.
+func (h *Hierarchy) resolveToolPath(toolPath string) (*ToolDefinition, string, string, error) {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
 
+	resolvedPath := toolPath
+	for hops := 0; ; hops++ {
+		toolDef, err := h.lookupTool(resolvedPath)
+		if err != nil {
+			return nil, "", "", err
+		}
+		if toolDef != nil {
+			// Return the tool and its server name (from the tool-level server field)
+			return toolDef, toolDef.Server, resolvedPath, nil
+		}
+
+		next, ok := h.followRedirect(resolvedPath)
+		if !ok || hops >= maxRedirectHops {
+			return nil, "", "", fmt.Errorf("tool not found: %s", toolPath)
+		}
+		resolvedPath = next
+	}
+}
+
+// lookupTool finds a tool at an exact (current) path, returning nil if there is none
+// Caller must hold h.mu
+func (h *Hierarchy) lookupTool(toolPath string) (*ToolDefinition, error) {
 	// Parse the tool path
 	parts := strings.Split(toolPath, ".")
 	if len(parts) == 0 {
-		return nil, "", fmt.Errorf("invalid tool path: %s", toolPath)
+		return nil, fmt.Errorf("invalid tool path: %s", toolPath)
 	}
 
 	var foundTool *ToolDefinition
@@ -391,12 +702,82 @@ func (h *Hierarchy) ResolveToolPath(toolPath string) (*ToolDefinition, string, e
 		}
 	}
 
-	if foundTool == nil {
-		return nil, "", fmt.Errorf("tool not found: %s", toolPath)
+	// Strategy 3: A bare tool name that isn't at the root is looked up in the global index
+	// e.g., "search" -> "files.search" when exactly one category has a "search" tool
+	if foundTool == nil && len(parts) == 1 {
+		entries := h.toolIndex[toolPath]
+		switch len(entries) {
+		case 0:
+			// Fall through to not found
+		case 1:
+			foundTool = entries[0].tool
+		default:
+			candidates := make([]string, len(entries))
+			for i, entry := range entries {
+				candidates[i] = entry.path
+			}
+			return nil, &AmbiguousToolError{Name: toolPath, Candidates: candidates}
+		}
 	}
 
-	// Return the tool and its server name (from the tool-level server field)
-	return foundTool, foundTool.Server, nil
+	return foundTool, nil
+}
+
+// canonicalToolPath returns the full path of a resolved tool
+// Bare names and other shorthand resolve to the same tool but not to its full path,
+// which is needed where tools are keyed by path, e.g. usage counts and pinned tools
+func (h *Hierarchy) canonicalToolPath(toolDef *ToolDefinition, toolPath string) string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, entry := range h.toolIndex[toolPath[strings.LastIndex(toolPath, ".")+1:]] {
+		if entry.tool == toolDef {
+			return entry.path
+		}
+	}
+	return toolPath
+}
+
+// HandleDescribeTool handles the describe_tool meta-tool
+// Returns the full definition of a tool, including its input and output schemas,
+// so the model knows the exact argument names before calling execute_tool
+func (h *Hierarchy) HandleDescribeTool(toolPath string) (map[string]interface{}, error) {
+	toolDef, serverName, resolvedPath, err := h.resolveToolPath(toolPath)
+	if err != nil {
+		return nil, err
+	}
+
+	response := map[string]interface{}{
+		"tool_path": resolvedPath,
+		"maps_to":   toolDef.MapsTo,
+	}
+	if resolvedPath != toolPath {
+		response["deprecation"] = deprecationNote(toolPath, resolvedPath)
+	}
+
+	if toolDef.Title != "" {
+		response["title"] = toolDef.Title
+	}
+	if toolDef.Description != "" {
+		response["description"] = toolDef.Description
+	}
+	if serverName != "" {
+		response["server"] = serverName
+	}
+	if toolDef.InputSchema != nil {
+		response["inputSchema"] = toolDef.VisibleInputSchema()
+	} else {
+		// Tools without a schema still accept an (empty) arguments object
+		response["inputSchema"] = map[string]interface{}{"type": "object"}
+	}
+	if toolDef.OutputSchema != nil {
+		response["outputSchema"] = toolDef.OutputSchema
+	}
+	if len(toolDef.Annotations) > 0 {
+		response["annotations"] = toolDef.Annotations
+	}
+
+	return response, nil
 }
 
 // HandleExecuteTool handles the execute_tool meta-tool
@@ -404,11 +785,14 @@ func (h *Hierarchy) HandleExecuteTool(ctx context.Context, registry *ServerRegis
 	start := time.Now()
 
 	// Resolve the tool path to get tool definition and server name
-	toolDef, serverName, err := h.ResolveToolPath(toolPath)
+	toolDef, serverName, resolvedPath, err := h.resolveToolPath(toolPath)
 	if err != nil {
 		log.Printf("Tool resolution failed for %s: %v", toolPath, err)
 		return nil, err
 	}
+	if resolvedPath != toolPath {
+		log.Printf("Deprecated tool path %s redirected to %s", toolPath, resolvedPath)
+	}
 
 	if serverName == "" {
 		log.Printf("No MCP server configured for tool: %s", toolPath)
@@ -416,17 +800,49 @@ func (h *Hierarchy) HandleExecuteTool(ctx context.Context, registry *ServerRegis
 	}
 
 	// Check if server was disabled during preload
-	if disabled, reason := registry.IsDisabled(serverName); disabled {
-		log.Printf("Attempted to use disabled server %s for tool %s: %s", serverName, toolPath, reason)
-		return nil, &DisabledServerError{
-			Server:  serverName,
-			Reason:  reason,
-			Message: fmt.Sprintf("Server '%s' is disabled: %s. Check logs for details.", serverName, reason),
-		}
+	if err := registry.CheckEnabled(serverName); err != nil {
+		log.Printf("Attempted to use disabled server %s for tool %s: %v", serverName, toolPath, err)
+		return nil, err
 	}
 
 	log.Printf("Resolved tool: path=%s, server=%s, maps_to=%s", toolPath, serverName, toolDef.MapsTo)
 
+	// Merge default_args and fixed_args, then auto-wrap arguments in 'params' if the schema requires it
+	// This handles Python MCP servers that use Pydantic models expecting a params wrapper
+	wrappedArguments := h.applyToolArguments(toolDef, arguments)
+
+	// Validate arguments before touching the server, so malformed calls fail fast
+	// instead of paying for a cold start and a server-specific error
+	validate := registry.ArgumentValidationEnabled(serverName)
+	if toolDef.ValidateArguments != nil {
+		validate = *toolDef.ValidateArguments
+	}
+	if validate {
+		if violations := ValidateArguments(toolDef.InputSchema, wrappedArguments); len(violations) > 0 {
+			log.Printf("Argument validation failed for %s: %v", toolPath, violations)
+			return nil, &ArgumentValidationError{ToolPath: toolPath, Violations: violations}
+		}
+	}
+
+	// Ask the user before running destructive tools, with the arguments that will be sent
+	// Patterns match the tool's full path, however the caller named it
+	canonicalPath := h.canonicalToolPath(toolDef, resolvedPath)
+	if err := h.confirmToolCall(ctx, canonicalPath, serverName, toolDef, wrappedArguments); err != nil {
+		return nil, err
+	}
+
+	// Servers that can't take parallel calls run them one slot at a time
+	queueStart := time.Now()
+	release, err := registry.AcquireCall(ctx, serverName)
+	if err != nil {
+		log.Printf("No call slot on %s after %v: %v", serverName, time.Since(queueStart), err)
+		return nil, err
+	}
+	defer release()
+	if waited := time.Since(queueStart); waited > 10*time.Millisecond {
+		log.Printf("Waited %v for a call slot on %s", waited, serverName)
+	}
+
 	// Get or load the MCP client for this server
 	loadStart := time.Now()
 	client, err := registry.GetOrLoadServer(ctx, serverName)
@@ -439,17 +855,17 @@ func (h *Hierarchy) HandleExecuteTool(ctx context.Context, registry *ServerRegis
 	// Use the mapped tool name
 	actualToolName := toolDef.MapsTo
 	if actualToolName == "" {
-		actualToolName = strings.Split(toolPath, ".")[len(strings.Split(toolPath, "."))-1]
+		actualToolName = strings.Split(resolvedPath, ".")[len(strings.Split(resolvedPath, "."))-1]
 	}
 
-	// Auto-wrap arguments in 'params' if the schema requires it
-	// This handles Python MCP servers that use Pydantic models expecting a params wrapper
-	wrappedArguments := h.maybeWrapInParams(toolDef, arguments)
-
 	log.Printf("Executing tool: hierarchy_path=%s, server=%s, tool=%s", toolPath, serverName, actualToolName)
 
-	// Create a context with 60-second timeout for tool execution (increased from 15s)
-	toolCtx, cancel := context.WithTimeout(ctx, 60*time.Second)
+	// Long-running tools can raise the server's call timeout (60s by default)
+	callTimeout := registry.CallTimeout(serverName)
+	if toolDef.TimeoutMs > 0 {
+		callTimeout = time.Duration(toolDef.TimeoutMs) * time.Millisecond
+	}
+	toolCtx, cancel := context.WithTimeout(ctx, callTimeout)
 	defer cancel()
 
 	// Call the tool on the actual MCP server
@@ -458,16 +874,13 @@ func (h *Hierarchy) HandleExecuteTool(ctx context.Context, registry *ServerRegis
 	callRequest.Params.Name = actualToolName
 	callRequest.Params.Arguments = wrappedArguments
 
-	result, err := client.GetClient().CallTool(toolCtx, callRequest)
+	result, err := client.CallTool(toolCtx, callRequest)
 	if err != nil {
-		// Check if this is a transport error (broken pipe, connection reset, etc.)
-		// If so, remove the client and retry once with a fresh connection
-		errStr := err.Error()
-		if strings.Contains(errStr, "broken pipe") ||
-			strings.Contains(errStr, "connection reset") ||
-			strings.Contains(errStr, "transport error") {
+		// If the request never reached the server (dead process, broken connection),
+		// remove the client and retry once with a fresh connection
+		if client.ConnectionLost(err) {
 			log.Printf("Transport error for %s, attempting reconnection: %v", serverName, err)
-			registry.RemoveClient(serverName)
+			registry.RemoveClient(serverName, client)
 
 			// Retry with fresh connection
 			client, err = registry.GetOrLoadServer(ctx, serverName)
@@ -477,10 +890,10 @@ func (h *Hierarchy) HandleExecuteTool(ctx context.Context, registry *ServerRegis
 			}
 
 			// Retry the tool call with fresh connection
-			retryCtx, retryCancel := context.WithTimeout(ctx, 60*time.Second)
+			retryCtx, retryCancel := context.WithTimeout(ctx, callTimeout)
 			defer retryCancel()
 
-			result, err = client.GetClient().CallTool(retryCtx, callRequest)
+			result, err = client.CallTool(retryCtx, callRequest)
 			if err != nil {
 				log.Printf("Tool call failed after reconnection for %s: %v", actualToolName, err)
 				return nil, fmt.Errorf("failed to call tool %s after reconnection: %w", actualToolName, err)
@@ -494,6 +907,18 @@ func (h *Hierarchy) HandleExecuteTool(ctx context.Context, registry *ServerRegis
 
 	log.Printf("Tool %s completed in %v (total: %v)", actualToolName, time.Since(callStart), time.Since(start))
 
+	// Page oversized results instead of dumping them into the model's context
+	maxResultBytes := registry.MaxResultBytes(serverName)
+	if toolDef.MaxResultBytes > 0 {
+		maxResultBytes = toolDef.MaxResultBytes
+	}
+	result = h.results.limitResult(resolvedPath, result, maxResultBytes)
+	h.recordUsage(canonicalPath)
+
+	if resolvedPath != toolPath {
+		result = withDeprecationNote(result, toolPath, resolvedPath)
+	}
+
 	return result, nil
 }
 
@@ -506,47 +931,7 @@ func (h *Hierarchy) maybeWrapInParams(toolDef *ToolDefinition, arguments map[str
 		return arguments
 	}
 
-	// Check if the schema requires params wrapper
-	if toolDef.InputSchema == nil {
-		return arguments
-	}
-
-	// Check if 'params' is in the required fields
-	required, ok := toolDef.InputSchema["required"]
-	if !ok {
-		return arguments
-	}
-
-	requiredList, ok := required.([]interface{})
-	if !ok {
-		return arguments
-	}
-
-	// Check if 'params' is required and is the only/primary required field
-	paramsRequired := false
-	for _, r := range requiredList {
-		if str, ok := r.(string); ok && str == "params" {
-			paramsRequired = true
-			break
-		}
-	}
-
-	if !paramsRequired {
-		return arguments
-	}
-
-	// Verify 'params' is defined in properties
-	props, ok := toolDef.InputSchema["properties"]
-	if !ok {
-		return arguments
-	}
-
-	propsMap, ok := props.(map[string]interface{})
-	if !ok {
-		return arguments
-	}
-
-	if _, hasParamsProp := propsMap["params"]; !hasParamsProp {
+	if !requiresParamsWrapper(toolDef.InputSchema) {
 		return arguments
 	}
 
@@ -557,10 +942,17 @@ func (h *Hierarchy) maybeWrapInParams(toolDef *ToolDefinition, arguments map[str
 	}
 }
 
+// Default server timeouts, overridable with the initTimeoutMs and callTimeoutMs options
+const (
+	DefaultInitTimeoutMs = 5000
+	DefaultCallTimeoutMs = 60000
+)
+
 // DisabledServerError is returned when attempting to use a disabled server
 type DisabledServerError struct {
 	Server  string
 	Reason  string
+	RetryAt time.Time // When the server is probed again
 	Message string
 }
 
@@ -570,66 +962,234 @@ func (e *DisabledServerError) Error() string {
 
 // ServerRegistry manages MCP client connections
 type ServerRegistry struct {
-	clients         map[string]*client.Client
-	serverConfigs   map[string]*config.MCPClientConfigV2
-	disabledServers map[string]string // server name -> error reason/code
-	mu              sync.RWMutex
+	clients          map[string]*client.Client
+	serverConfigs    map[string]*config.MCPClientConfigV2
+	breakers         map[string]*serverBreaker // servers that failed to start, closed breakers are removed
+	hierarchyServers map[string]bool           // servers whose config came from hierarchy mcp_server blocks
+	loading          map[string]*serverLoad    // servers being started right now
+	processes        map[string]*serverProcess // restart history of supervised stdio servers
+	idle             map[string]*idleServer    // running servers with an idle timeout
+	limiters         map[string]*callLimiter   // call slots of servers with maxConcurrentCalls
+	closed           bool
+	ctx              context.Context // Lives until Close, runs the ping tasks
+	cancel           context.CancelFunc
+	onLoaded         func(serverName string, c *client.Client)
+	mu               sync.RWMutex
 }
 
 // NewServerRegistry creates a new server registry with server configurations
 func NewServerRegistry(serverConfigs map[string]*config.MCPClientConfigV2) *ServerRegistry {
+	// Copy the configs, servers from the hierarchy are merged in later
+	configs := make(map[string]*config.MCPClientConfigV2, len(serverConfigs))
+	for name, cfg := range serverConfigs {
+		configs[name] = cfg
+	}
+	ctx, cancel := context.WithCancel(context.Background())
 	return &ServerRegistry{
-		clients:         make(map[string]*client.Client),
-		serverConfigs:   serverConfigs,
-		disabledServers: make(map[string]string),
+		clients:          make(map[string]*client.Client),
+		serverConfigs:    configs,
+		breakers:         make(map[string]*serverBreaker),
+		hierarchyServers: make(map[string]bool),
+		loading:          make(map[string]*serverLoad),
+		processes:        make(map[string]*serverProcess),
+		idle:             make(map[string]*idleServer),
+		limiters:         make(map[string]*callLimiter),
+		ctx:              ctx,
+		cancel:           cancel,
 	}
 }
 
-// DisableServer marks a server as unavailable with reason
-func (r *ServerRegistry) DisableServer(name string, reason string) {
+// OnServerLoaded sets a callback invoked in the background after a server is loaded
+// Used to expose the server's prompts and resources once it is running
+func (r *ServerRegistry) OnServerLoaded(fn func(serverName string, c *client.Client)) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	r.disabledServers[name] = reason
-	log.Printf("Server %s DISABLED: %s", name, reason)
+	r.onLoaded = fn
 }
 
-// IsDisabled checks if server was disabled during preload
+// IsDisabled checks if the server's circuit breaker is open
 func (r *ServerRegistry) IsDisabled(name string) (bool, string) {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	reason, disabled := r.disabledServers[name]
-	return disabled, reason
+	if err := r.disabledError(name); err != nil {
+		return true, err.Reason
+	}
+	return false, ""
 }
 
-// GetOrLoadServer gets an existing client or creates and initializes a new one
-// This implements lazy loading - servers are only started when first accessed
-func (r *ServerRegistry) GetOrLoadServer(ctx context.Context, serverName string) (*client.Client, error) {
-	// First check with read lock
+// ArgumentValidationEnabled reports whether execute_tool arguments should be validated
+// against the inputSchema for tools on this server (enabled unless turned off in options)
+func (r *ServerRegistry) ArgumentValidationEnabled(name string) bool {
 	r.mu.RLock()
-	if client, exists := r.clients[serverName]; exists {
-		r.mu.RUnlock()
-		return client, nil
+	defer r.mu.RUnlock()
+	cfg, exists := r.serverConfigs[name]
+	if !exists || cfg.Options == nil {
+		return true
 	}
-	r.mu.RUnlock()
+	return cfg.Options.ValidateArguments.OrElse(true)
+}
 
-	// Need to create, use write lock
-	r.mu.Lock()
-	defer r.mu.Unlock()
+// MaxResultBytes returns the result size above which tool results are paged (0 = unlimited)
+func (r *ServerRegistry) MaxResultBytes(name string) int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	cfg, exists := r.serverConfigs[name]
+	if !exists || cfg.Options == nil {
+		return 0
+	}
+	return cfg.Options.MaxResultBytes.OrElse(0)
+}
 
-	// Check again in case another goroutine created it
+// CallTimeout returns how long a tool call, resource read or prompt may take on a server
+func (r *ServerRegistry) CallTimeout(name string) time.Duration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	timeoutMs := DefaultCallTimeoutMs
+	if cfg, exists := r.serverConfigs[name]; exists && cfg.Options != nil && cfg.Options.CallTimeoutMs.OrElse(0) > 0 {
+		timeoutMs = cfg.Options.CallTimeoutMs.OrElse(DefaultCallTimeoutMs)
+	}
+	return time.Duration(timeoutMs) * time.Millisecond
+}
+
+// initTimeout returns how long a server may take to start and initialize
+func initTimeout(cfg *config.MCPClientConfigV2) time.Duration {
+	timeoutMs := DefaultInitTimeoutMs
+	if cfg.Options != nil && cfg.Options.InitTimeoutMs.OrElse(0) > 0 {
+		timeoutMs = cfg.Options.InitTimeoutMs.OrElse(DefaultInitTimeoutMs)
+	}
+	return time.Duration(timeoutMs) * time.Millisecond
+}
+
+// GetOrLoadServer returns the client for a server, starting and initializing it on first use
+// Starts are coordinated per server: concurrent callers share one in-flight attempt, and
+// the registry lock is only held for map access, so a slow server never blocks the others
+func (r *ServerRegistry) GetOrLoadServer(ctx context.Context, serverName string) (*client.Client, error) {
+	r.mu.Lock()
 	if client, exists := r.clients[serverName]; exists {
+		r.touch(serverName)
+		r.mu.Unlock()
 		return client, nil
 	}
+	load, inFlight := r.loading[serverName]
+	if !inFlight {
+		var err error
+		if load, err = r.startLoad(ctx, serverName, false); err != nil {
+			r.mu.Unlock()
+			return nil, err
+		}
+	}
+	r.mu.Unlock()
 
-	// Look up the server config
+	if inFlight {
+		log.Printf("Waiting for MCP server %s, already starting", serverName)
+	}
+	return load.wait(ctx, serverName)
+}
+
+// StartServerQuietly starts a server that is neither running nor starting, without the
+// OnServerLoaded callback, for callers that handle the new client themselves
+// Returns nil and no error when the server is already up or starting
+func (r *ServerRegistry) StartServerQuietly(ctx context.Context, serverName string) (*client.Client, error) {
+	r.mu.Lock()
+	if _, exists := r.clients[serverName]; exists {
+		r.mu.Unlock()
+		return nil, nil
+	}
+	if _, inFlight := r.loading[serverName]; inFlight {
+		r.mu.Unlock()
+		return nil, nil
+	}
+	load, err := r.startLoad(ctx, serverName, true)
+	r.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return load.wait(ctx, serverName)
+}
+
+// startLoad starts a server in the background, caller must hold r.mu
+func (r *ServerRegistry) startLoad(ctx context.Context, serverName string, quiet bool) (*serverLoad, error) {
+	if err := r.disabledError(serverName); err != nil {
+		return nil, err
+	}
 	cfg, exists := r.serverConfigs[serverName]
 	if !exists {
 		return nil, fmt.Errorf("server config not found: %s", serverName)
 	}
+	if b, exists := r.breakers[serverName]; exists {
+		b.state = BreakerHalfOpen // This start is the probe
+	}
+	load := &serverLoad{done: make(chan struct{}), quiet: quiet}
+	r.loading[serverName] = load
+	// The start outlives a caller that gives up, the others may still be waiting
+	go r.loadServer(ctx, serverName, cfg, load)
+	return load, nil
+}
+
+// serverLoad is an in-flight server start, shared by every caller waiting for it
+type serverLoad struct {
+	done   chan struct{} // Closed once client or err is set
+	quiet  bool          // Skip the OnServerLoaded callback
+	client *client.Client
+	err    error
+}
+
+// wait returns the result of the start, or gives up with ctx
+func (l *serverLoad) wait(ctx context.Context, serverName string) (*client.Client, error) {
+	select {
+	case <-l.done:
+		return l.client, l.err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("gave up waiting for MCP server %s: %w", serverName, ctx.Err())
+	}
+}
+
+// loadServer starts and initializes a server, then publishes the result to load
+// ctx is the context of the caller that triggered the start; only its values are
+// used, so the start and the ping task outlive the caller. Pings stop when the client
+// is closed or the registry is
+func (r *ServerRegistry) loadServer(ctx context.Context, serverName string, cfg *config.MCPClientConfigV2, load *serverLoad) {
+	mcpClient, err := r.startServer(context.WithoutCancel(ctx), serverName, cfg)
+
+	r.mu.Lock()
+	delete(r.loading, serverName)
+	if err == nil && r.closed {
+		_ = mcpClient.Close()
+		mcpClient, err = nil, fmt.Errorf("server registry closed while starting %s", serverName)
+	}
+	if err == nil {
+		r.clients[serverName] = mcpClient
+		r.watchIdle(serverName, mcpClient)
+	}
+	r.recordLoadResult(serverName, err)
+	onLoaded := r.onLoaded
+	r.mu.Unlock()
 
-	// Create a context with 5-second timeout for server initialization
+	load.client, load.err = mcpClient, err
+	close(load.done)
+	if err != nil {
+		return
+	}
+
+	// Start ping task if needed, stdio servers are pinged by their supervisor
+	if mcpClient.NeedPing() {
+		go mcpClient.StartPingTask(r.ctx)
+	}
+	if mcpClient.Exited() != nil {
+		go r.supervise(serverName, mcpClient)
+	}
+
+	// Notify in the background, the callback talks to the server
+	if onLoaded != nil && !load.quiet {
+		go onLoaded(serverName, mcpClient)
+	}
+}
+
+// startServer creates, starts and initializes the client for a server
+func (r *ServerRegistry) startServer(ctx context.Context, serverName string, cfg *config.MCPClientConfigV2) (*client.Client, error) {
+	// Create a context with the init timeout (5 seconds by default) for server initialization
 	// This enables fast-fail detection when servers crash or are unresponsive
-	initCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	initCtx, cancel := context.WithTimeout(ctx, initTimeout(cfg))
 	defer cancel()
 
 	start := time.Now()
@@ -646,6 +1206,7 @@ func (r *ServerRegistry) GetOrLoadServer(ctx context.Context, serverName string)
 		log.Printf("Starting MCP client: %s", serverName)
 		err := mcpClient.GetClient().Start(initCtx)
 		if err != nil {
+			_ = mcpClient.Close()
 			return nil, fmt.Errorf("failed to start MCP client %s: %w", serverName, err)
 		}
 	}
@@ -659,19 +1220,11 @@ func (r *ServerRegistry) GetOrLoadServer(ctx context.Context, serverName string)
 
 	_, err = mcpClient.GetClient().Initialize(initCtx, initRequest)
 	if err != nil {
+		_ = mcpClient.Close()
 		return nil, fmt.Errorf("failed to initialize MCP client %s: %w", serverName, err)
 	}
 
 	log.Printf("Created and initialized MCP client for server: %s (took %v)", serverName, time.Since(start))
-
-	// Store the client
-	r.clients[serverName] = mcpClient
-
-	// Start ping task if needed
-	if mcpClient.NeedPing() {
-		go mcpClient.StartPingTask(ctx)
-	}
-
 	return mcpClient, nil
 }
 
@@ -679,6 +1232,22 @@ func (r *ServerRegistry) GetOrLoadServer(ctx context.Context, serverName string)
 func (r *ServerRegistry) Close() {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	r.closed = true // Servers still starting are closed when their start finishes
+	r.cancel()
+
+	for _, b := range r.breakers {
+		if b.timer != nil {
+			b.timer.Stop()
+		}
+	}
+	for _, p := range r.processes {
+		if p.timer != nil {
+			p.timer.Stop()
+		}
+	}
+	for _, s := range r.idle {
+		s.timer.Stop()
+	}
 
 	for name, client := range r.clients {
 		log.Printf("Closing MCP client: %s", name)
@@ -686,19 +1255,46 @@ func (r *ServerRegistry) Close() {
 	}
 }
 
-// RemoveClient removes a client from the registry, allowing it to be recreated
+// RemoveClient removes a failed client from the registry, allowing it to be recreated
 // on the next GetOrLoadServer call. Used for reconnection after transport errors.
-func (r *ServerRegistry) RemoveClient(serverName string) {
+// A client that was already replaced, e.g. by the supervisor, is left alone
+func (r *ServerRegistry) RemoveClient(serverName string, failed *client.Client) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if client, exists := r.clients[serverName]; exists {
+	if client, exists := r.clients[serverName]; exists && client == failed {
 		log.Printf("Removing failed MCP client: %s (will reconnect on next call)", serverName)
 		_ = client.Close()
 		delete(r.clients, serverName)
 	}
 }
 
+// IsRunning reports whether a server has a live client
+func (r *ServerRegistry) IsRunning(serverName string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, exists := r.clients[serverName]
+	return exists
+}
+
+// CloseServer closes a running server unless a call is using it; the next use starts it again
+func (r *ServerRegistry) CloseServer(serverName string, c *client.Client) {
+	r.mu.Lock()
+	if r.clients[serverName] != c || c.Busy() {
+		r.mu.Unlock()
+		return
+	}
+	delete(r.clients, serverName)
+	if s, exists := r.idle[serverName]; exists {
+		s.timer.Stop()
+		delete(r.idle, serverName)
+	}
+	r.mu.Unlock()
+
+	log.Printf("Closing MCP client: %s", serverName)
+	_ = c.Close()
+}
+
 // GetServerNames returns all configured server names
 func (r *ServerRegistry) GetServerNames() []string {
 	r.mu.RLock()
@@ -734,9 +1330,8 @@ func (r *ServerRegistry) PreloadServers(ctx context.Context) {
 			start := time.Now()
 			_, err := r.GetOrLoadServer(ctx, serverName)
 			if err != nil {
-				// Parse error to determine cause and disable the server
+				// The failed start opened the server's breaker, it is retried with backoff
 				errorCode, _ := secrets.ParseErrorFromStderr(err.Error())
-				r.DisableServer(serverName, string(errorCode))
 				log.Printf("Preload FAILED for %s [%s]: %v (took %v)", serverName, errorCode, err, time.Since(start))
 				countMu.Lock()
 				failCount++
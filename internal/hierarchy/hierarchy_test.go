@@ -0,0 +1,1346 @@
+package hierarchy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/IAMSamuelRodda/mcp-proxy/internal/client"
+	"github.com/IAMSamuelRodda/mcp-proxy/internal/config"
+	"github.com/TBXark/optional-go"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestHierarchy writes a small hierarchy using both the nested and flat layouts:
+//
+//	root.json
+//	github/github.json                 (branch)
+//	github/create_issue.json           (flat leaf)
+//	github/search_code.json            (flat leaf)
+//	files/files.json                   (branch)
+//	files/read_file.json               (flat leaf)
+//	files/search/search.json           (branch)
+//	files/search/search_code.json      (flat leaf, same tool name as github)
+func writeTestHierarchy(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+
+	files := map[string]string{
+		"root.json":          `{"overview": "Root: 2 servers; github -> GitHub API, files -> Local filesystem"}`,
+		"github/github.json": `{"overview": "github: GitHub repositories, issues and pull requests"}`,
+		"github/create_issue.json": `{"tools": {"create_issue": {
+			"description": "Create a new issue in a repository",
+			"server": "github",
+			"inputSchema": {
+				"type": "object",
+				"properties": {
+					"owner": {"type": "string"},
+					"repo": {"type": "string"},
+					"title": {"type": "string"},
+					"labels": {"type": "array", "items": {"type": "string"}}
+				},
+				"required": ["owner", "repo", "title"]
+			}
+		}}}`,
+		"github/search_code.json": `{"tools": {"search_code": {
+			"description": "Search code across GitHub repositories",
+			"server": "github",
+			"inputSchema": {"type": "object", "properties": {"q": {"type": "string"}}, "required": ["q"]}
+		}}}`,
+		"files/files.json": `{"overview": "files: Local filesystem access"}`,
+		"files/read_file.json": `{"tools": {"read_file": {
+			"description": "Read the contents of a file",
+			"server": "filesystem",
+			"inputSchema": {"type": "object", "properties": {"path": {"type": "string"}}, "required": ["path"]}
+		}}}`,
+		"files/search/search.json": `{"overview": "search: Find files and content"}`,
+		"files/search/search_code.json": `{"tools": {"search_code": {
+			"description": "Grep for a pattern in local files",
+			"server": "filesystem",
+			"inputSchema": {"type": "object", "properties": {"pattern": {"type": "string"}}}
+		}}}`,
+	}
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+	return dir
+}
+
+func loadTestHierarchy(t *testing.T) *Hierarchy {
+	t.Helper()
+	h, err := LoadHierarchy(writeTestHierarchy(t))
+	require.NoError(t, err)
+	return h
+}
+
+func TestSearchToolsRanksNameMatchesFirst(t *testing.T) {
+	h := loadTestHierarchy(t)
+
+	response, err := h.HandleSearchTools("create issue", 0)
+	require.NoError(t, err)
+
+	results := response["results"].([]ToolSearchResult)
+	require.NotEmpty(t, results)
+	assert.Equal(t, "github.create_issue", results[0].ToolPath)
+	assert.Equal(t, "github", results[0].Server)
+}
+
+func TestSearchToolsMatchesSchemaPropertiesAndOverviews(t *testing.T) {
+	h := loadTestHierarchy(t)
+
+	// "labels" only appears as an inputSchema property
+	response, err := h.HandleSearchTools("labels", 0)
+	require.NoError(t, err)
+	results := response["results"].([]ToolSearchResult)
+	require.Len(t, results, 1)
+	assert.Equal(t, "github.create_issue", results[0].ToolPath)
+
+	// "filesystem" only appears in category overviews
+	response, err = h.HandleSearchTools("filesystem", 0)
+	require.NoError(t, err)
+	results = response["results"].([]ToolSearchResult)
+	assert.Len(t, results, 2)
+}
+
+func TestSearchToolsLimit(t *testing.T) {
+	h := loadTestHierarchy(t)
+
+	response, err := h.HandleSearchTools("search code", 1)
+	require.NoError(t, err)
+
+	results := response["results"].([]ToolSearchResult)
+	assert.Len(t, results, 1)
+	assert.Equal(t, 2, response["total_matches"])
+}
+
+func TestSearchToolsRequiresQuery(t *testing.T) {
+	h := loadTestHierarchy(t)
+
+	_, err := h.HandleSearchTools("  ", 0)
+	assert.Error(t, err)
+}
+
+func TestDescribeToolReturnsSchema(t *testing.T) {
+	h := loadTestHierarchy(t)
+
+	response, err := h.HandleDescribeTool("github.create_issue")
+	require.NoError(t, err)
+
+	assert.Equal(t, "github", response["server"])
+	assert.Equal(t, "create_issue", response["maps_to"])
+	schema := response["inputSchema"].(map[string]interface{})
+	assert.Contains(t, schema["properties"], "labels")
+
+	_, err = h.HandleDescribeTool("github.missing")
+	assert.Error(t, err)
+}
+
+func TestResolveToolPathBareName(t *testing.T) {
+	h := loadTestHierarchy(t)
+
+	// Unique bare name resolves through the global index
+	tool, server, err := h.ResolveToolPath("create_issue")
+	require.NoError(t, err)
+	assert.Equal(t, "github", server)
+	assert.Equal(t, "create_issue", tool.MapsTo)
+
+	// Ambiguous bare name lists every candidate
+	_, _, err = h.ResolveToolPath("search_code")
+	var ambiguous *AmbiguousToolError
+	require.ErrorAs(t, err, &ambiguous)
+	assert.Equal(t, []string{"files.search.search_code", "github.search_code"}, ambiguous.Candidates)
+
+	// Full paths still resolve directly
+	_, server, err = h.ResolveToolPath("files.search.search_code")
+	require.NoError(t, err)
+	assert.Equal(t, "filesystem", server)
+}
+
+func TestValidateArguments(t *testing.T) {
+	h := loadTestHierarchy(t)
+	tool, _, err := h.ResolveToolPath("github.create_issue")
+	require.NoError(t, err)
+
+	valid := map[string]interface{}{
+		"owner":  "acme",
+		"repo":   "widgets",
+		"title":  "Bug",
+		"labels": []interface{}{"bug"},
+	}
+	assert.Empty(t, ValidateArguments(tool.InputSchema, valid))
+
+	invalid := map[string]interface{}{
+		"owner":  "acme",
+		"title":  42.0,
+		"labels": []interface{}{"bug", true},
+	}
+	assert.Equal(t, []string{
+		"repo: required property missing",
+		"labels[1]: expected string, got boolean",
+		"title: expected string, got integer",
+	}, ValidateArguments(tool.InputSchema, invalid))
+}
+
+func TestValidateArgumentsEnumAndAdditionalProperties(t *testing.T) {
+	schema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"state": map[string]interface{}{"type": "string", "enum": []interface{}{"open", "closed"}},
+			"filter": map[string]interface{}{
+				"type":                 "object",
+				"properties":           map[string]interface{}{"limit": map[string]interface{}{"type": "integer"}},
+				"additionalProperties": false,
+			},
+		},
+		"additionalProperties": false,
+	}
+
+	violations := ValidateArguments(schema, map[string]interface{}{
+		"state":  "merged",
+		"filter": map[string]interface{}{"limit": 1.5, "sort": "asc"},
+		"extra":  true,
+	})
+	assert.Equal(t, []string{
+		"extra: unknown property (expected one of: filter, state)",
+		"filter.limit: expected integer, got number",
+		"filter.sort: unknown property (expected one of: limit)",
+		`state: must be one of ["open", "closed"]`,
+	}, violations)
+}
+
+func TestReloadSwapsTreeAndKeepsLastGoodOnError(t *testing.T) {
+	dir := writeTestHierarchy(t)
+	h, err := LoadHierarchy(dir)
+	require.NoError(t, err)
+
+	// A new tool appears after reload, including in the bare-name index
+	newTool := `{"tools": {"list_prs": {"description": "List pull requests", "server": "github"}}}`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "github", "list_prs.json"), []byte(newTool), 0644))
+	require.NoError(t, h.Reload())
+
+	_, server, err := h.ResolveToolPath("list_prs")
+	require.NoError(t, err)
+	assert.Equal(t, "github", server)
+
+	// A broken file aborts the reload and the previous tree stays in place
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "github", "broken.json"), []byte(`{"tools": `), 0644))
+	require.NoError(t, os.Remove(filepath.Join(dir, "github", "list_prs.json")))
+	assert.Error(t, h.Reload())
+
+	_, _, err = h.ResolveToolPath("github.list_prs")
+	assert.NoError(t, err)
+}
+
+func TestReloadToleratesFilesBrokenFromTheStart(t *testing.T) {
+	dir := writeTestHierarchy(t)
+	broken := filepath.Join(dir, "github", "broken.json")
+	require.NoError(t, os.WriteFile(broken, []byte(`{"tools": `), 0644))
+	h, err := LoadHierarchy(dir)
+	require.NoError(t, err)
+
+	// Edits elsewhere still reload while the old broken file stays skipped
+	newTool := `{"tools": {"list_prs": {"description": "List pull requests", "server": "github"}}}`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "github", "list_prs.json"), []byte(newTool), 0644))
+	require.NoError(t, h.Reload())
+	_, _, err = h.ResolveToolPath("github.list_prs")
+	assert.NoError(t, err)
+
+	// Breaking a file that parsed before still aborts the reload
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "github", "list_prs.json"), []byte(`{"tools": `), 0644))
+	assert.Error(t, h.Reload())
+	_, _, err = h.ResolveToolPath("github.list_prs")
+	assert.NoError(t, err)
+
+	// Once fixed, the file loads like any other
+	fixed := `{"tools": {"merge_pr": {"description": "Merge a pull request", "server": "github"}}}`
+	require.NoError(t, os.WriteFile(broken, []byte(fixed), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "github", "list_prs.json"), []byte(newTool), 0644))
+	require.NoError(t, h.Reload())
+	_, _, err = h.ResolveToolPath("merge_pr")
+	assert.NoError(t, err)
+}
+
+func TestFingerprintChangesOnWrite(t *testing.T) {
+	dir := writeTestHierarchy(t)
+
+	before, err := fingerprintHierarchy(dir)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "files", "write_file.json"), []byte(`{"tools": {}}`), 0644))
+	after, err := fingerprintHierarchy(dir)
+	require.NoError(t, err)
+	assert.NotEqual(t, before, after)
+}
+
+func TestGetToolsInCategoryUsesTree(t *testing.T) {
+	h := loadTestHierarchy(t)
+
+	root, err := h.HandleGetToolsInCategory("", 1, 0)
+	require.NoError(t, err)
+	children := root["children"].(map[string]interface{})
+	assert.Len(t, children, 2)
+	assert.Contains(t, children, "files")
+	assert.Contains(t, children, "github")
+
+	files, err := h.HandleGetToolsInCategory("files", 1, 0)
+	require.NoError(t, err)
+	children = files["children"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"is_leaf": true, "tool_count": 1}, children["read_file"])
+	assert.Contains(t, children["search"], "overview")
+
+	// Leaf-only category aggregates its children's tools
+	github, err := h.HandleGetToolsInCategory("github", 1, 0)
+	require.NoError(t, err)
+	tools := github["tools"].(map[string]interface{})
+	assert.Equal(t, "github.create_issue", tools["create_issue"].(map[string]interface{})["tool_path"])
+
+	_, err = h.HandleGetToolsInCategory("missing", 1, 0)
+	assert.Error(t, err)
+}
+
+func TestGetToolsInCategoryIsDeterministic(t *testing.T) {
+	h := loadTestHierarchy(t)
+
+	first, err := h.HandleGetToolsInCategory("files", 1, 0)
+	require.NoError(t, err)
+	expected, err := json.Marshal(first)
+	require.NoError(t, err)
+
+	for i := 0; i < 20; i++ {
+		response, err := h.HandleGetToolsInCategory("files", 1, 0)
+		require.NoError(t, err)
+		actual, err := json.Marshal(response)
+		require.NoError(t, err)
+		assert.Equal(t, string(expected), string(actual))
+	}
+}
+
+func TestGetToolsInCategoryDepth(t *testing.T) {
+	h := loadTestHierarchy(t)
+
+	response, err := h.HandleGetToolsInCategory("", 3, 0)
+	require.NoError(t, err)
+
+	// files.search is expanded two levels below the root
+	files := response["children"].(map[string]interface{})["files"].(map[string]interface{})
+	search := files["children"].(map[string]interface{})["search"].(map[string]interface{})
+	tools := search["tools"].(map[string]interface{})
+	assert.Equal(t, "files.search.search_code", tools["search_code"].(map[string]interface{})["tool_path"])
+	assert.NotContains(t, response, "truncated")
+}
+
+func TestGetToolsInCategoryTokenBudget(t *testing.T) {
+	h := loadTestHierarchy(t)
+
+	shallow, err := h.HandleGetToolsInCategory("", 1, 0)
+	require.NoError(t, err)
+
+	// A budget that only fits the first level leaves every branch collapsed
+	response, err := h.HandleGetToolsInCategory("", 3, h.EstimateTokens(shallow)+1)
+	require.NoError(t, err)
+	assert.Equal(t, true, response["truncated"])
+
+	github := response["children"].(map[string]interface{})["github"].(map[string]interface{})
+	assert.Equal(t, true, github["truncated"])
+	assert.NotContains(t, github, "tools")
+}
+
+func TestResourceURINamespacing(t *testing.T) {
+	uri := NamespaceResourceURI("filesystem", "file:///tmp/notes.txt")
+	assert.Equal(t, "mcp-proxy://filesystem/file:///tmp/notes.txt", uri)
+
+	server, original, err := ParseResourceURI(uri)
+	require.NoError(t, err)
+	assert.Equal(t, "filesystem", server)
+	assert.Equal(t, "file:///tmp/notes.txt", original)
+
+	_, _, err = ParseResourceURI("file:///tmp/notes.txt")
+	assert.Error(t, err)
+	_, _, err = ParseResourceURI("mcp-proxy://filesystem")
+	assert.Error(t, err)
+}
+
+func TestExecuteToolsReportsEachCallInOrder(t *testing.T) {
+	h := loadTestHierarchy(t)
+	registry := NewServerRegistry(nil)
+
+	calls := []BatchCall{
+		{ToolPath: "github.missing"},
+		{ToolPath: "github.create_issue", Arguments: map[string]interface{}{"owner": "acme"}},
+		{ToolPath: ""},
+	}
+	response, err := h.HandleExecuteTools(context.Background(), registry, calls, 2)
+	require.NoError(t, err)
+
+	results := response["results"].([]BatchCallResult)
+	require.Len(t, results, 3)
+	for i, result := range results {
+		assert.Equal(t, i, result.Index)
+		assert.Equal(t, calls[i].ToolPath, result.ToolPath)
+		assert.False(t, result.Success)
+	}
+	assert.Contains(t, results[0].Error, "not found")
+	assert.Contains(t, results[1].Error, "repo: required property missing")
+	assert.Equal(t, "tool_path is required", results[2].Error)
+	assert.Equal(t, 3, response["failed"])
+
+	_, err = h.HandleExecuteTools(context.Background(), registry, nil, 2)
+	assert.Error(t, err)
+}
+
+func TestResolveToolPathFollowsAliasesAndRedirects(t *testing.T) {
+	dir := writeTestHierarchy(t)
+	moved := map[string]string{
+		// The github category used to be called "gh", and create_issue used to be "open_issue"
+		"github/github.json": `{"overview": "github: GitHub repositories", "aliases": ["gh"], "redirects": {"open_issue": "github.create_issue"}}`,
+		"files/read_file.json": `{"tools": {"read_file": {
+			"description": "Read the contents of a file",
+			"server": "filesystem",
+			"aliases": ["fs.cat"]
+		}}}`,
+	}
+	for name, content := range moved {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	h, err := LoadHierarchy(dir)
+	require.NoError(t, err)
+
+	for oldPath, newPath := range map[string]string{
+		"gh.create_issue":   "github.create_issue",
+		"github.open_issue": "github.create_issue",
+		"gh.open_issue":     "github.create_issue",
+		"fs.cat":            "files.read_file",
+	} {
+		toolDef, _, resolvedPath, err := h.resolveToolPath(oldPath)
+		require.NoError(t, err, oldPath)
+		assert.Equal(t, newPath, resolvedPath, oldPath)
+		assert.NotNil(t, toolDef)
+	}
+
+	described, err := h.HandleDescribeTool("gh.create_issue")
+	require.NoError(t, err)
+	assert.Equal(t, "github.create_issue", described["tool_path"])
+	assert.Contains(t, described["deprecation"], "deprecated")
+
+	category, err := h.HandleGetToolsInCategory("gh", 1, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "github", category["path"])
+
+	_, _, err = h.ResolveToolPath("gh.missing")
+	assert.EqualError(t, err, "tool not found: gh.missing")
+}
+
+func TestFixedAndDefaultArguments(t *testing.T) {
+	tool := &ToolDefinition{
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"owner": map[string]interface{}{"type": "string"},
+				"repo":  map[string]interface{}{"type": "string"},
+				"state": map[string]interface{}{"type": "string"},
+			},
+			"required": []interface{}{"owner", "repo", "state"},
+		},
+		FixedArgs:   map[string]interface{}{"owner": "acme"},
+		DefaultArgs: map[string]interface{}{"state": "open"},
+	}
+	h := &Hierarchy{}
+
+	// Fixed values win over the model, defaults only fill gaps
+	merged := h.applyToolArguments(tool, map[string]interface{}{"owner": "someone", "repo": "widgets"})
+	assert.Equal(t, map[string]interface{}{"owner": "acme", "repo": "widgets", "state": "open"}, merged)
+	merged = h.applyToolArguments(tool, map[string]interface{}{"repo": "widgets", "state": "closed"})
+	assert.Equal(t, "closed", merged["state"])
+
+	// The model doesn't see fixed arguments and defaulted ones become optional
+	visible := tool.VisibleInputSchema()
+	props := visible["properties"].(map[string]interface{})
+	assert.NotContains(t, props, "owner")
+	assert.Equal(t, "open", props["state"].(map[string]interface{})["default"])
+	assert.Equal(t, []interface{}{"repo"}, visible["required"])
+	assert.Contains(t, tool.InputSchema["properties"], "owner", "stored schema must stay intact")
+}
+
+func TestFixedArgumentsInsideParamsWrapper(t *testing.T) {
+	tool := &ToolDefinition{
+		InputSchema: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"params": map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"database": map[string]interface{}{"type": "string"},
+						"query":    map[string]interface{}{"type": "string"},
+					},
+					"required": []interface{}{"database", "query"},
+				},
+			},
+			"required": []interface{}{"params"},
+		},
+		FixedArgs: map[string]interface{}{"database": "analytics"},
+	}
+	h := &Hierarchy{}
+
+	expected := map[string]interface{}{
+		"params": map[string]interface{}{"database": "analytics", "query": "select 1"},
+	}
+	assert.Equal(t, expected, h.applyToolArguments(tool, map[string]interface{}{"query": "select 1"}))
+	assert.Equal(t, expected, h.applyToolArguments(tool, map[string]interface{}{
+		"params": map[string]interface{}{"query": "select 1"},
+	}))
+
+	params := tool.VisibleInputSchema()["properties"].(map[string]interface{})["params"].(map[string]interface{})
+	assert.NotContains(t, params["properties"], "database")
+	assert.Equal(t, []interface{}{"query"}, params["required"])
+}
+
+func TestLimitResultPagesLargeText(t *testing.T) {
+	store := NewResultStore(time.Minute)
+	text := strings.Repeat("héllo wörld ", 50) // Multi-byte characters must never be split
+	result := &mcp.CallToolResult{Content: []mcp.Content{mcp.NewTextContent(text)}}
+
+	// Small results pass through untouched
+	assert.Same(t, result, store.limitResult("logs.read", result, len(text)*2))
+
+	limited := store.limitResult("logs.read", result, 100)
+	require.Len(t, limited.Content, 2)
+	firstPage := limited.Content[0].(mcp.TextContent).Text
+	assert.LessOrEqual(t, len(firstPage), 100)
+	assert.Contains(t, limited.Content[1].(mcp.TextContent).Text, "fetch_result_page")
+
+	// Paging through every offset reassembles the full text
+	handle := ""
+	for h := range store.entries {
+		handle = h
+	}
+	var reassembled strings.Builder
+	for offset := 0; ; {
+		page, err := store.Page(handle, offset)
+		require.NoError(t, err)
+		assert.True(t, utf8.ValidString(page.Text))
+		reassembled.WriteString(page.Text)
+		if page.NextOffset == 0 {
+			break
+		}
+		offset = page.NextOffset
+	}
+	assert.Equal(t, text, reassembled.String())
+
+	_, err := store.Page("res_missing", 0)
+	assert.Error(t, err)
+}
+
+func TestHierarchyDeclaredServers(t *testing.T) {
+	dir := writeTestHierarchy(t)
+	github := `{"overview": "github: GitHub API", "mcp_server": {
+		"name": "github",
+		"type": "stdio",
+		"command": "npx",
+		"args": ["-y", "@modelcontextprotocol/server-github"],
+		"tool_mappings": {"create_issue": "issues_create"}
+	}}`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "github", "github.json"), []byte(github), 0644))
+	h, err := LoadHierarchy(dir)
+	require.NoError(t, err)
+
+	// tool_mappings apply to tools without an explicit maps_to
+	toolDef, _, err := h.ResolveToolPath("github.create_issue")
+	require.NoError(t, err)
+	assert.Equal(t, "issues_create", toolDef.MapsTo)
+
+	configs, issues := h.ServerConfigs(nil, true)
+	assert.Empty(t, issues)
+	require.Contains(t, configs, "github")
+	assert.Equal(t, "npx", configs["github"].Command)
+
+	// The main config wins over the hierarchy and differences are reported
+	registry := NewServerRegistry(map[string]*config.MCPClientConfigV2{
+		"github": {Command: "/usr/local/bin/github-mcp"},
+	})
+	conflicts := registry.MergeServerConfigs(configs)
+	require.Len(t, conflicts, 1)
+	assert.Contains(t, conflicts[0], "github")
+
+	// Without a conflict the hierarchy server becomes loadable
+	registry = NewServerRegistry(nil)
+	assert.Empty(t, registry.MergeServerConfigs(configs))
+	assert.Equal(t, []string{"github"}, registry.GetServerNames())
+}
+
+func TestHierarchyServerEnvExpansion(t *testing.T) {
+	dir := writeTestHierarchy(t)
+	github := `{"overview": "github: GitHub API", "mcp_server": {
+		"name": "github",
+		"command": "/usr/bin/github-mcp",
+		"args": ["--token", "${MCP_PROXY_TEST_TOKEN}"],
+		"env": {"HOME_DIR": "${MCP_PROXY_TEST_UNSET}"}
+	}}`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "github", "github.json"), []byte(github), 0644))
+	t.Setenv("MCP_PROXY_TEST_TOKEN", "secret")
+	h, err := LoadHierarchy(dir)
+	require.NoError(t, err)
+
+	// Set variables expand, unset ones expand to "" and are reported
+	configs, issues := h.ServerConfigs(nil, true)
+	assert.Equal(t, []string{"--token", "secret"}, configs["github"].Args)
+	assert.Equal(t, "", configs["github"].Env["HOME_DIR"])
+	require.Len(t, issues, 1)
+	assert.Equal(t, IssueUnsetVariable, issues[0].Kind)
+	assert.Equal(t, "github", issues[0].Path)
+	assert.Contains(t, issues[0].Message, "MCP_PROXY_TEST_UNSET")
+
+	// With -expand-env=false the block is used as written
+	configs, issues = h.ServerConfigs(nil, false)
+	assert.Equal(t, []string{"--token", "${MCP_PROXY_TEST_TOKEN}"}, configs["github"].Args)
+	assert.Empty(t, issues)
+}
+
+func TestTimeoutsFromOptions(t *testing.T) {
+	registry := NewServerRegistry(map[string]*config.MCPClientConfigV2{
+		"slow":    {Command: "slow-mcp", Options: &config.OptionsV2{CallTimeoutMs: optional.NewField(300000), InitTimeoutMs: optional.NewField(20000)}},
+		"default": {Command: "default-mcp"},
+	})
+	assert.Equal(t, 5*time.Minute, registry.CallTimeout("slow"))
+	assert.Equal(t, 60*time.Second, registry.CallTimeout("default"))
+	assert.Equal(t, 60*time.Second, registry.CallTimeout("unknown"))
+	assert.Equal(t, 20*time.Second, initTimeout(registry.serverConfigs["slow"]))
+	assert.Equal(t, 5*time.Second, initTimeout(registry.serverConfigs["default"]))
+}
+
+type fakeConfirmer struct {
+	approve  bool
+	err      error
+	requests []ConfirmationRequest
+}
+
+func (f *fakeConfirmer) Confirm(ctx context.Context, request ConfirmationRequest) (bool, error) {
+	f.requests = append(f.requests, request)
+	return f.approve, f.err
+}
+
+func TestConfirmationPolicy(t *testing.T) {
+	h := loadTestHierarchy(t)
+	toolDef, _, err := h.ResolveToolPath("github.create_issue")
+	require.NoError(t, err)
+	toolDef.Annotations = map[string]interface{}{"destructiveHint": true}
+	registry := NewServerRegistry(nil)
+	args := map[string]interface{}{"owner": "o", "repo": "r", "title": "t"}
+	var denied *ConfirmationDeniedError
+
+	// Declined calls never reach the server
+	confirmer := &fakeConfirmer{}
+	h.SetConfirmationPolicy(&ConfirmationPolicy{Destructive: true, Confirmer: confirmer})
+	_, err = h.HandleExecuteTool(context.Background(), registry, "github.create_issue", args)
+	require.ErrorAs(t, err, &denied)
+	require.Len(t, confirmer.requests, 1)
+	assert.Equal(t, "github.create_issue", confirmer.requests[0].ToolPath)
+	assert.Contains(t, confirmer.requests[0].Message(), `"title": "t"`)
+
+	// Approved calls go on to the server, which doesn't exist here
+	confirmer.approve = true
+	_, err = h.HandleExecuteTool(context.Background(), registry, "github.create_issue", args)
+	require.Error(t, err)
+	assert.False(t, errors.As(err, &denied))
+
+	// Tools that are neither destructive nor matched run without asking
+	_, err = h.HandleExecuteTool(context.Background(), registry, "github.search_code", map[string]interface{}{"q": "x"})
+	assert.False(t, errors.As(err, &denied))
+	assert.Len(t, confirmer.requests, 2)
+
+	// Patterns match within path segments, and the fallback applies without elicitation
+	unsupported := &fakeConfirmer{err: ErrConfirmationUnsupported}
+	h.SetConfirmationPolicy(&ConfirmationPolicy{Patterns: []string{"files.*"}, Confirmer: unsupported})
+	_, err = h.HandleExecuteTool(context.Background(), registry, "files.read_file", map[string]interface{}{"path": "/tmp"})
+	require.ErrorAs(t, err, &denied)
+	_, err = h.HandleExecuteTool(context.Background(), registry, "files.search.search_code", map[string]interface{}{})
+	assert.False(t, errors.As(err, &denied))
+
+	h.SetConfirmationPolicy(&ConfirmationPolicy{Patterns: []string{"files.*"}, AllowWhenUnsupported: true, Confirmer: unsupported})
+	_, err = h.HandleExecuteTool(context.Background(), registry, "files.read_file", map[string]interface{}{"path": "/tmp"})
+	assert.False(t, errors.As(err, &denied))
+}
+
+func TestConfirmationPatternsMatchCanonicalPath(t *testing.T) {
+	dir := writeTestHierarchy(t)
+	// files.read_file used to live in an "fs" category and is also reachable as "fs.cat"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "files", "files.json"),
+		[]byte(`{"overview": "files: Local filesystem access", "aliases": ["fs"], "redirects": {"cat": "read_file"}}`), 0644))
+	h, err := LoadHierarchy(dir)
+	require.NoError(t, err)
+	registry := NewServerRegistry(nil)
+	confirmer := &fakeConfirmer{}
+	h.SetConfirmationPolicy(&ConfirmationPolicy{Patterns: []string{"files.*"}, Confirmer: confirmer})
+
+	// A bare name, a shorthand path, an alias and a redirect are all gated like the full path
+	for _, toolPath := range []string{"files.read_file", "read_file", "files.read_file.read_file", "fs.read_file", "files.cat"} {
+		_, err := h.HandleExecuteTool(context.Background(), registry, toolPath, map[string]interface{}{"path": "/tmp"})
+		var denied *ConfirmationDeniedError
+		require.ErrorAs(t, err, &denied, toolPath)
+		assert.Equal(t, "files.read_file", denied.ToolPath, toolPath)
+	}
+	require.Len(t, confirmer.requests, 5)
+	for _, request := range confirmer.requests {
+		assert.Equal(t, "files.read_file", request.ToolPath)
+	}
+}
+
+// blockingConfirmer never answers, like a user who walked away
+type blockingConfirmer struct{}
+
+func (blockingConfirmer) Confirm(ctx context.Context, request ConfirmationRequest) (bool, error) {
+	<-ctx.Done()
+	return false, ctx.Err()
+}
+
+func TestConfirmationTimeoutDenies(t *testing.T) {
+	h := loadTestHierarchy(t)
+	registry := NewServerRegistry(nil)
+	h.SetConfirmationPolicy(&ConfirmationPolicy{
+		Patterns:             []string{"files.*"},
+		AllowWhenUnsupported: true, // A timeout is an answer, not a missing client
+		Timeout:              50 * time.Millisecond,
+		Confirmer:            blockingConfirmer{},
+	})
+
+	start := time.Now()
+	_, err := h.HandleExecuteTool(context.Background(), registry, "files.read_file", map[string]interface{}{"path": "/tmp"})
+	var denied *ConfirmationDeniedError
+	require.ErrorAs(t, err, &denied)
+	assert.Contains(t, denied.Reason, "did not answer within 50ms")
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestPinnedToolsPrefixCollidingNames(t *testing.T) {
+	h := loadTestHierarchy(t)
+
+	pinned, errs := h.PinnedTools([]string{
+		"github.create_issue",
+		"github.search_code",
+		"files.search.search_code",
+		"github.create_issue", // Duplicates are pinned once
+		"missing.tool",
+	}, []string{"execute_tool", "create_issue"})
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), "missing.tool")
+
+	names := make(map[string]string)
+	for _, pt := range pinned {
+		names[pt.ToolPath] = pt.Name
+	}
+	assert.Equal(t, map[string]string{
+		"github.create_issue":      "github_create_issue",
+		"github.search_code":       "github_search_code",
+		"files.search.search_code": "filesystem_search_code",
+	}, names)
+
+	// The MCP definition carries the real schema
+	raw, err := json.Marshal(pinned[0].Tool)
+	require.NoError(t, err)
+	assert.Contains(t, string(raw), `"required":["owner","repo","title"]`)
+}
+
+func TestUsageStatsPersistAndRank(t *testing.T) {
+	dir := writeTestHierarchy(t)
+	h, err := LoadHierarchy(dir)
+	require.NoError(t, err)
+
+	statePath := UsageStatePath(dir)
+	assert.Equal(t, dir+".usage.json", statePath)
+	usage, err := LoadUsageStats(statePath)
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		usage.Record("files.read_file")
+	}
+	usage.Record("github.create_issue")
+	usage.Record("github.create_issue")
+	usage.Record("github.search_code")
+	usage.Record("removed.tool")
+	require.NoError(t, usage.Save())
+
+	reloaded, err := LoadUsageStats(statePath)
+	require.NoError(t, err)
+	assert.Equal(t, 3, reloaded.Count("files.read_file"))
+	assert.Equal(t, []string{"files.read_file", "github.create_issue", "github.search_code", "removed.tool"}, reloaded.Ranked())
+
+	// Top-N skips removed and statically pinned tools
+	assert.Equal(t, []string{"github.create_issue", "github.search_code"},
+		h.MostUsedTools(reloaded, 2, 0, []string{"read_file"}))
+
+	// The token budget keeps large schemas out, smaller ones still fit
+	createIssue, _, err := h.ResolveToolPath("github.create_issue")
+	require.NoError(t, err)
+	budget := h.EstimateTokens(createIssue.mcpTool("create_issue")) - 1
+	assert.NotContains(t, h.MostUsedTools(reloaded, 3, budget, nil), "github.create_issue")
+}
+
+func TestLoadYAMLBundle(t *testing.T) {
+	bundle := `
+overview: "Root: 2 servers"
+categories:
+  github:
+    overview: "github: GitHub API"
+    tools:
+      create_issue:
+        description: Create a new issue
+        server: github
+        timeout_ms: 30000
+        inputSchema:
+          type: object
+          properties:
+            title: {type: string}
+    categories:
+      search:
+        overview: "search: Code search"
+        tools:
+          search_code: {description: Search code, server: github}
+  files:
+    overview: "files: Local filesystem"
+    aliases: [filesystem]
+    tools:
+      read_file: {description: Read a file, server: filesystem}
+`
+	path := filepath.Join(t.TempDir(), "hierarchy.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(bundle), 0644))
+
+	h, err := LoadHierarchy(path)
+	require.NoError(t, err)
+
+	toolDef, server, err := h.ResolveToolPath("github.create_issue")
+	require.NoError(t, err)
+	assert.Equal(t, "github", server)
+	assert.Equal(t, 30000, toolDef.TimeoutMs)
+
+	_, _, err = h.ResolveToolPath("github.search.search_code")
+	require.NoError(t, err)
+	_, _, err = h.ResolveToolPath("filesystem.read_file")
+	require.NoError(t, err, "category aliases work in bundles too")
+
+	root, err := h.HandleGetToolsInCategory("", 1, 0)
+	require.NoError(t, err)
+	assert.Len(t, root["children"], 2)
+
+	// Editing the bundle is picked up by the watcher
+	before, err := fingerprintHierarchy(path)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, []byte(bundle+"\n# edited\n"), 0644))
+	after, err := fingerprintHierarchy(path)
+	require.NoError(t, err)
+	assert.NotEqual(t, before, after)
+}
+
+func TestCheckReportsProblems(t *testing.T) {
+	dir := writeTestHierarchy(t)
+	problems := map[string]string{
+		"files/broken.json":         `{"tools": {`,
+		"github/github.json":        `{"overview": "github", "overview": "GitHub API"}`,
+		"github/list_repos.json":    `{"tools": {"list_repos": {"server": "github"}}}`,
+		"orphan/nested/nested.json": `{"tools": {"ping": {"server": "gitlab", "inputSchema": {"type": "object"}}}}`,
+	}
+	for name, content := range problems {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+
+	h, err := LoadHierarchy(dir)
+	require.NoError(t, err, "the initial load skips broken files")
+
+	registry := NewServerRegistry(map[string]*config.MCPClientConfigV2{
+		"github":     {Command: "github-mcp"},
+		"filesystem": {Command: "filesystem-mcp"},
+	})
+	report := h.Check(registry)
+
+	found := make(map[string]string)
+	for _, issue := range report.Issues {
+		found[issue.Kind+" "+issue.Path] = issue.Severity
+	}
+	assert.Equal(t, SeverityError, found[IssueParseError+" "+filepath.Join(dir, "files/broken.json")])
+	assert.Equal(t, SeverityError, found[IssueDuplicateKey+" "+filepath.Join(dir, "github/github.json")])
+	assert.Equal(t, SeverityError, found[IssueDanglingServer+" orphan.nested.ping"])
+	assert.Equal(t, SeverityWarning, found[IssueUnreachableNode+" orphan.nested"])
+	assert.Equal(t, SeverityWarning, found[IssueNoSchema+" github.list_repos"])
+	assert.Len(t, report.Issues, 5, "the rest of the test hierarchy is valid")
+	assert.Equal(t, 3, report.Count(SeverityError))
+	assert.Equal(t, SeverityError, report.Issues[0].Severity, "errors come first")
+}
+
+func TestListingBudgetAndTokenTally(t *testing.T) {
+	h := loadTestHierarchy(t)
+
+	full, err := h.HandleGetToolsInCategory("github", 1, 0)
+	require.NoError(t, err)
+	tools := full["tools"].(map[string]interface{})
+	require.Len(t, tools, 2)
+	assert.NotContains(t, full, "more_available")
+
+	// A budget too small for any tool cuts them all and says so
+	h.SetListingBudget(1)
+	empty, err := h.HandleGetToolsInCategory("github", 1, 0)
+	require.NoError(t, err)
+	assert.Empty(t, empty["tools"])
+	more, ok := empty["more_available"].(map[string]interface{})
+	require.True(t, ok)
+	assert.Equal(t, 2, more["tools"])
+
+	// Tools are cut from the end of the listing, before any children
+	h.SetListingBudget(h.EstimateTokens(full) - 1)
+	response, err := h.HandleGetToolsInCategory("github", 1, 0)
+	require.NoError(t, err)
+	kept := response["tools"].(map[string]interface{})
+	require.Less(t, len(kept), 2)
+	if len(kept) == 1 {
+		assert.Contains(t, kept, "create_issue")
+	}
+	assert.Equal(t, 2-len(kept), response["more_available"].(map[string]interface{})["tools"])
+
+	// Search results are cut from the lowest ranked
+	h.SetListingBudget(0)
+	search, err := h.HandleSearchTools("search code", 0)
+	require.NoError(t, err)
+	results := search["results"].([]ToolSearchResult)
+	require.Len(t, results, 2)
+	h.SetListingBudget(h.EstimateTokens(search) - 1)
+	search, err = h.HandleSearchTools("search code", 0)
+	require.NoError(t, err)
+	keptResults := search["results"].([]ToolSearchResult)
+	require.Less(t, len(keptResults), 2)
+	assert.Equal(t, results[:len(keptResults)], keptResults)
+	assert.Equal(t, 2-len(keptResults), search["more_available"].(map[string]interface{})["results"])
+
+	// The estimator is pluggable, and the baseline covers every tool
+	_, toolCount := h.FullExposureTokens()
+	assert.Equal(t, 4, toolCount)
+	h.SetTokenEstimator(func(text string) int { return 1 })
+	assert.Equal(t, 1, h.EstimateTokens(full))
+	fullTokens, _ := h.FullExposureTokens()
+	assert.Equal(t, 4, fullTokens, "changing the estimator resets the cached baseline")
+
+	h.Tokens().SetDefinition("execute_tool", 1)
+	h.Tokens().AddResponse(2)
+	assert.Equal(t, TokenUsage{Definitions: 1, Responses: 2, Calls: 1}, h.Tokens().Usage())
+	assert.Contains(t, h.TokenSummary(), "spent ~3 tokens")
+	assert.NotContains(t, h.TokenSummary(), "tool results")
+
+	// Tool output is reported next to the proxy's cost, not as part of it
+	h.Tokens().AddToolOutput(5)
+	assert.Equal(t, TokenUsage{Definitions: 1, Responses: 2, Calls: 1, ToolOutput: 5, ToolCalls: 1}, h.Tokens().Usage())
+	assert.Contains(t, h.TokenSummary(), "spent ~3 tokens")
+	assert.Contains(t, h.TokenSummary(), "plus ~5 tokens in 1 tool results")
+}
+
+func TestGetOrLoadServerSingleFlight(t *testing.T) {
+	// The server swallows initialize, so the start hangs until the init timeout
+	dd, err := exec.LookPath("dd")
+	if err != nil {
+		t.Skip("dd not available")
+	}
+	registry := NewServerRegistry(map[string]*config.MCPClientConfigV2{
+		"hanging": {Command: dd, Args: []string{"of=/dev/null", "status=none"}, Options: &config.OptionsV2{InitTimeoutMs: optional.NewField(500)}},
+	})
+	defer registry.Close()
+	warm := &client.Client{}
+	registry.clients["warm"] = warm
+
+	errs := make(chan error, 3)
+	for i := 0; i < 3; i++ {
+		go func() {
+			_, err := registry.GetOrLoadServer(context.Background(), "hanging")
+			errs <- err
+		}()
+	}
+	require.Eventually(t, func() bool {
+		registry.mu.Lock()
+		defer registry.mu.Unlock()
+		return registry.loading["hanging"] != nil
+	}, time.Second, 5*time.Millisecond)
+
+	// A warm server is served while another one is starting
+	start := time.Now()
+	c, err := registry.GetOrLoadServer(context.Background(), "warm")
+	require.NoError(t, err)
+	assert.Same(t, warm, c)
+	assert.Less(t, time.Since(start), 100*time.Millisecond)
+
+	// A waiter can give up without failing the shared start
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = registry.GetOrLoadServer(ctx, "hanging")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// All callers get the result of the one attempt
+	var first error
+	for i := 0; i < 3; i++ {
+		err := <-errs
+		require.Error(t, err)
+		if first == nil {
+			first = err
+		}
+		assert.Same(t, first, err)
+	}
+	registry.mu.Lock()
+	assert.Empty(t, registry.loading)
+	assert.NotContains(t, registry.clients, "hanging")
+	registry.mu.Unlock()
+}
+
+func TestCircuitBreakerBacksOffAndRecovers(t *testing.T) {
+	cfg := &config.MCPClientConfigV2{Command: "x", Options: &config.OptionsV2{
+		RetryBaseDelayMs: optional.NewField(1000),
+		RetryMaxDelayMs:  optional.NewField(5000),
+	}}
+	assert.Equal(t, time.Second, retryDelay(cfg, 1))
+	assert.Equal(t, 4*time.Second, retryDelay(cfg, 3))
+	assert.Equal(t, 5*time.Second, retryDelay(cfg, 10))
+	assert.Equal(t, 5*time.Second, retryDelay(nil, 1))
+
+	dd, err := exec.LookPath("dd")
+	if err != nil {
+		t.Skip("dd not available")
+	}
+	registry := NewServerRegistry(map[string]*config.MCPClientConfigV2{
+		"flaky": {Command: dd, Args: []string{"of=/dev/null", "status=none"}, Options: &config.OptionsV2{
+			InitTimeoutMs:    optional.NewField(100),
+			RetryBaseDelayMs: optional.NewField(200),
+		}},
+	})
+	defer registry.Close()
+
+	// A failed start opens the breaker, callers fail fast with the retry time
+	_, err = registry.GetOrLoadServer(context.Background(), "flaky")
+	require.Error(t, err)
+	state, _, retryAt := registry.BreakerState("flaky")
+	assert.Equal(t, BreakerOpen, state)
+
+	var disabled *DisabledServerError
+	_, err = registry.GetOrLoadServer(context.Background(), "flaky")
+	require.ErrorAs(t, err, &disabled)
+	assert.Equal(t, retryAt, disabled.RetryAt)
+	assert.Contains(t, disabled.Error(), "Next retry in")
+	assert.ErrorAs(t, registry.CheckEnabled("flaky"), &disabled)
+
+	// The background probe fails again and doubles the backoff
+	require.Eventually(t, func() bool {
+		registry.mu.RLock()
+		defer registry.mu.RUnlock()
+		b := registry.breakers["flaky"]
+		return b != nil && b.failures == 2 && b.state == BreakerOpen
+	}, 2*time.Second, 10*time.Millisecond)
+	_, _, nextRetryAt := registry.BreakerState("flaky")
+	assert.Greater(t, nextRetryAt.Sub(retryAt), 300*time.Millisecond)
+
+	// A successful probe closes the breaker
+	registry.mu.Lock()
+	registry.recordLoadResult("flaky", nil)
+	registry.mu.Unlock()
+	state, _, _ = registry.BreakerState("flaky")
+	assert.Equal(t, BreakerClosed, state)
+	assert.NoError(t, registry.CheckEnabled("flaky"))
+	disabledNow, _ := registry.IsDisabled("flaky")
+	assert.False(t, disabledNow)
+}
+
+// TestHelperMCPServer is not a test: run as a child process with MCP_PROXY_TEST_SERVER=1
+// it serves MCP over stdio, with an "exit" tool that takes the process down
+func TestHelperMCPServer(t *testing.T) {
+	if os.Getenv("MCP_PROXY_TEST_SERVER") != "1" {
+		return
+	}
+	s := server.NewMCPServer("helper", "1.0.0")
+	s.AddTool(mcp.NewTool("exit"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		fmt.Fprintln(os.Stderr, "boom")
+		os.Exit(3)
+		return nil, nil
+	})
+	s.AddTool(mcp.NewTool("close_stderr"), func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		_ = os.Stderr.Close()
+		return mcp.NewToolResultText("closed"), nil
+	})
+	s.AddPrompt(mcp.NewPrompt("slow"), func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		time.Sleep(time.Second)
+		return mcp.NewGetPromptResult("slow", []mcp.PromptMessage{mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent("done"))}), nil
+	})
+	s.AddPrompt(mcp.NewPrompt("greet"), func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		return mcp.NewGetPromptResult("greeting", []mcp.PromptMessage{mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent("hello"))}), nil
+	})
+	_ = server.ServeStdio(s)
+	os.Exit(0)
+}
+
+func TestListPromptsThenCloseServer(t *testing.T) {
+	registry := NewServerRegistry(map[string]*config.MCPClientConfigV2{
+		"helper": {
+			Command: os.Args[0],
+			Args:    []string{"-test.run=TestHelperMCPServer"},
+			Env:     map[string]string{"MCP_PROXY_TEST_SERVER": "1"},
+		},
+	})
+	defer registry.Close()
+
+	c, err := registry.GetOrLoadServer(context.Background(), "helper")
+	require.NoError(t, err)
+	c.LoadPromptsAndResources(context.Background())
+	require.Len(t, c.LazyPrompts(), 2)
+	assert.Equal(t, "greet", c.LazyPrompts()[0].Name)
+
+	// The listing outlives the server, which starts again for the prompt itself
+	registry.CloseServer("helper", c)
+	assert.False(t, registry.IsRunning("helper"))
+	assert.Len(t, c.LazyPrompts(), 2)
+
+	result, err := HandleGetPrompt(context.Background(), registry, "helper", "greet", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "greeting", result.Description)
+	assert.True(t, registry.IsRunning("helper"))
+}
+
+func TestStartServerQuietlySkipsLoadCallback(t *testing.T) {
+	registry := NewServerRegistry(map[string]*config.MCPClientConfigV2{
+		"helper": {
+			Command: os.Args[0],
+			Args:    []string{"-test.run=TestHelperMCPServer"},
+			Env:     map[string]string{"MCP_PROXY_TEST_SERVER": "1"},
+		},
+	})
+	defer registry.Close()
+	loaded := make(chan *client.Client, 2)
+	registry.OnServerLoaded(func(serverName string, c *client.Client) { loaded <- c })
+
+	c, err := registry.StartServerQuietly(context.Background(), "helper")
+	require.NoError(t, err)
+	require.NotNil(t, c)
+	again, err := registry.StartServerQuietly(context.Background(), "helper")
+	require.NoError(t, err)
+	assert.Nil(t, again, "a running server is left to its own load")
+
+	// A regular start after closing it notifies as usual
+	registry.CloseServer("helper", c)
+	_, err = registry.GetOrLoadServer(context.Background(), "helper")
+	require.NoError(t, err)
+	select {
+	case notified := <-loaded:
+		assert.NotSame(t, c, notified)
+	case <-time.After(5 * time.Second):
+		t.Fatal("load callback not called")
+	}
+	assert.Empty(t, loaded)
+}
+
+func TestSupervisorRestartsExitedServer(t *testing.T) {
+	registry := NewServerRegistry(map[string]*config.MCPClientConfigV2{
+		"crashy": {
+			Command: os.Args[0],
+			Args:    []string{"-test.run=TestHelperMCPServer"},
+			Env:     map[string]string{"MCP_PROXY_TEST_SERVER": "1"},
+			Options: &config.OptionsV2{MaxRestarts: optional.NewField(1), RetryBaseDelayMs: optional.NewField(60000)},
+		},
+	})
+	defer registry.Close()
+	current := func() *client.Client {
+		registry.mu.RLock()
+		defer registry.mu.RUnlock()
+		return registry.clients["crashy"]
+	}
+
+	first, err := registry.GetOrLoadServer(context.Background(), "crashy")
+	require.NoError(t, err)
+
+	// A call that takes the process down fails right away instead of timing out
+	exitRequest := mcp.CallToolRequest{}
+	exitRequest.Params.Name = "exit"
+	start := time.Now()
+	_, err = first.CallTool(context.Background(), exitRequest)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "exit status 3: boom")
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	// The supervisor restarts it right away and records the exit
+	require.Eventually(t, func() bool {
+		c := current()
+		return c != nil && c != first
+	}, 5*time.Second, 10*time.Millisecond)
+	status := registry.ProcessStatus("crashy")
+	assert.Equal(t, 1, status.Restarts)
+	assert.Equal(t, "exit status 3: boom", status.LastExit)
+
+	// The next crash spends the restart budget and opens the breaker
+	_, _ = current().CallTool(context.Background(), exitRequest)
+	require.Eventually(t, func() bool {
+		state, _, _ := registry.BreakerState("crashy")
+		return state == BreakerOpen
+	}, 5*time.Second, 10*time.Millisecond)
+	_, reason, _ := registry.BreakerState("crashy")
+	assert.Equal(t, "CRASH_LOOP", reason)
+	assert.Nil(t, current())
+	assert.Equal(t, 1, registry.ProcessStatus("crashy").Restarts)
+}
+
+func TestServerThatClosesStderrKeepsRunning(t *testing.T) {
+	registry := NewServerRegistry(map[string]*config.MCPClientConfigV2{
+		"quiet": {
+			Command: os.Args[0],
+			Args:    []string{"-test.run=TestHelperMCPServer"},
+			Env:     map[string]string{"MCP_PROXY_TEST_SERVER": "1"},
+		},
+	})
+	defer registry.Close()
+
+	c, err := registry.GetOrLoadServer(context.Background(), "quiet")
+	require.NoError(t, err)
+	closeRequest := mcp.CallToolRequest{}
+	closeRequest.Params.Name = "close_stderr"
+	_, err = c.CallTool(context.Background(), closeRequest)
+	require.NoError(t, err)
+
+	// The end of stderr alone doesn't count as an exit while the server answers pings
+	select {
+	case <-c.Exited():
+		t.Fatalf("server treated as exited: %s", c.ExitStatus())
+	case <-time.After(500 * time.Millisecond):
+	}
+	_, err = c.CallTool(context.Background(), closeRequest)
+	require.NoError(t, err)
+	assert.Equal(t, 0, registry.ProcessStatus("quiet").Restarts)
+
+	// Killing it is still noticed
+	c.Kill()
+	select {
+	case <-c.Exited():
+	case <-time.After(5 * time.Second):
+		t.Fatal("exit not noticed")
+	}
+	assert.Contains(t, c.ExitStatus(), "killed")
+}
+
+func TestIdleServersAreClosedAndReloaded(t *testing.T) {
+	helper := func(options *config.OptionsV2) *config.MCPClientConfigV2 {
+		return &config.MCPClientConfigV2{
+			Command: os.Args[0],
+			Args:    []string{"-test.run=TestHelperMCPServer"},
+			Env:     map[string]string{"MCP_PROXY_TEST_SERVER": "1"},
+			Options: options,
+		}
+	}
+	registry := NewServerRegistry(map[string]*config.MCPClientConfigV2{
+		"idle": helper(&config.OptionsV2{IdleTimeoutMs: optional.NewField(200)}),
+		"warm": helper(&config.OptionsV2{IdleTimeoutMs: optional.NewField(200), KeepWarm: optional.NewField(true)}),
+		"long": helper(&config.OptionsV2{IdleTimeoutMs: optional.NewField(400)}),
+	})
+	defer registry.Close()
+	running := func(name string) bool {
+		registry.mu.RLock()
+		defer registry.mu.RUnlock()
+		return registry.clients[name] != nil
+	}
+
+	first, err := registry.GetOrLoadServer(context.Background(), "idle")
+	require.NoError(t, err)
+	_, err = registry.GetOrLoadServer(context.Background(), "warm")
+	require.NoError(t, err)
+
+	// Use keeps the server running past its idle timeout
+	for i := 0; i < 4; i++ {
+		time.Sleep(100 * time.Millisecond)
+		c, err := registry.GetOrLoadServer(context.Background(), "idle")
+		require.NoError(t, err)
+		assert.Same(t, first, c)
+	}
+
+	require.Eventually(t, func() bool { return !running("idle") }, 2*time.Second, 10*time.Millisecond)
+	<-first.Exited()
+	assert.True(t, running("warm"))
+	assert.Zero(t, registry.ProcessStatus("idle").Restarts, "closing an idle server is not a crash")
+
+	// The next use starts it again
+	second, err := registry.GetOrLoadServer(context.Background(), "idle")
+	require.NoError(t, err)
+	assert.NotSame(t, first, second)
+
+	// A prompt get that outlasts the idle timeout keeps the server running too, and the
+	// server gets a full idle timeout after the call finishes
+	long, err := registry.GetOrLoadServer(context.Background(), "long")
+	require.NoError(t, err)
+	done := make(chan error, 1)
+	go func() {
+		_, err := HandleGetPrompt(context.Background(), registry, "long", "slow", nil)
+		done <- err
+	}()
+	time.Sleep(600 * time.Millisecond)
+	assert.True(t, running("long"), "server closed during a prompt get")
+	require.NoError(t, <-done)
+	assert.False(t, long.Busy())
+	time.Sleep(300 * time.Millisecond)
+	assert.True(t, running("long"), "server closed right after a long prompt get")
+	require.Eventually(t, func() bool { return !running("long") }, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestAcquireCallLimitsAndQueues(t *testing.T) {
+	registry := NewServerRegistry(map[string]*config.MCPClientConfigV2{
+		"serial": {Command: "/bin/true", Options: &config.OptionsV2{MaxConcurrentCalls: optional.NewField(1), MaxQueue: optional.NewField(1)}},
+		"open":   {Command: "/bin/true"},
+	})
+	defer registry.Close()
+
+	// Servers without a limit never wait
+	for i := 0; i < 3; i++ {
+		release, err := registry.AcquireCall(context.Background(), "open")
+		require.NoError(t, err)
+		defer release()
+	}
+
+	release, err := registry.AcquireCall(context.Background(), "serial")
+	require.NoError(t, err)
+
+	// The next call waits for the slot
+	queued := make(chan func(), 1)
+	go func() {
+		r, err := registry.AcquireCall(context.Background(), "serial")
+		assert.NoError(t, err)
+		queued <- r
+	}()
+	require.Eventually(t, func() bool {
+		registry.mu.RLock()
+		l := registry.limiters["serial"]
+		registry.mu.RUnlock()
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		return l.waiting == 1
+	}, time.Second, 5*time.Millisecond)
+
+	// With the queue full, callers fail fast
+	_, err = registry.AcquireCall(context.Background(), "serial")
+	var busy *ServerBusyError
+	require.ErrorAs(t, err, &busy)
+	assert.Equal(t, "serial", busy.Server)
+	assert.Contains(t, err.Error(), "busy")
+
+	// Releasing the slot hands it to the queued call
+	release()
+	select {
+	case r := <-queued:
+		defer r()
+	case <-time.After(time.Second):
+		t.Fatal("queued call never got the slot")
+	}
+
+	// Waiting stops with the context
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = registry.AcquireCall(ctx, "serial")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
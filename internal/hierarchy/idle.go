@@ -0,0 +1,85 @@
+package hierarchy
+
+import (
+	"log"
+	"time"
+
+	"github.com/IAMSamuelRodda/mcp-proxy/internal/client"
+	"github.com/IAMSamuelRodda/mcp-proxy/internal/config"
+)
+
+// idleServer tracks when a running server with an idle timeout was last used
+type idleServer struct {
+	lastUsed time.Time
+	timer    *time.Timer // Next idle check
+}
+
+// idleTimeout is how long a server may go unused before it is closed, 0 keeps it running
+func idleTimeout(cfg *config.MCPClientConfigV2) time.Duration {
+	if cfg == nil || cfg.Options == nil || cfg.Options.KeepWarm.OrElse(false) {
+		return 0
+	}
+	return time.Duration(cfg.Options.IdleTimeoutMs.OrElse(0)) * time.Millisecond
+}
+
+// watchIdle starts the idle checks of a server that was just loaded
+// Caller must hold r.mu
+func (r *ServerRegistry) watchIdle(serverName string, c *client.Client) {
+	if old, exists := r.idle[serverName]; exists {
+		old.timer.Stop()
+		delete(r.idle, serverName)
+	}
+	timeout := idleTimeout(r.serverConfigs[serverName])
+	if timeout <= 0 {
+		return
+	}
+	r.idle[serverName] = &idleServer{
+		lastUsed: time.Now(),
+		timer:    time.AfterFunc(timeout, func() { r.evictIfIdle(serverName, c) }),
+	}
+}
+
+// touch marks a server as used, caller must hold r.mu
+func (r *ServerRegistry) touch(serverName string) {
+	if s, exists := r.idle[serverName]; exists {
+		s.lastUsed = time.Now()
+	}
+}
+
+// evictIfIdle closes a server that hasn't been used within its idle timeout, otherwise
+// it checks again when the timeout could next run out. A call counts as use when it
+// starts and when it finishes, so a long call gets a full timeout after it returns
+// The next GetOrLoadServer starts the server again
+func (r *ServerRegistry) evictIfIdle(serverName string, c *client.Client) {
+	r.mu.Lock()
+	s, exists := r.idle[serverName]
+	if r.closed || !exists || r.clients[serverName] != c {
+		r.mu.Unlock()
+		return // Closed, or replaced by a newer client with its own checks
+	}
+	timeout := idleTimeout(r.serverConfigs[serverName])
+	if timeout <= 0 {
+		delete(r.idle, serverName) // Config changed to keep it running
+		r.mu.Unlock()
+		return
+	}
+	if done := c.LastRequestDone(); done.After(s.lastUsed) {
+		s.lastUsed = done
+	}
+	idleFor := time.Since(s.lastUsed)
+	if idleFor < timeout || c.Busy() {
+		next := timeout - idleFor
+		if next <= 0 {
+			next = timeout // Still busy with a call
+		}
+		s.timer = time.AfterFunc(next, func() { r.evictIfIdle(serverName, c) })
+		r.mu.Unlock()
+		return
+	}
+	delete(r.idle, serverName)
+	delete(r.clients, serverName)
+	r.mu.Unlock()
+
+	log.Printf("Closing idle MCP server %s (unused for %v)", serverName, idleFor.Round(time.Second))
+	_ = c.Close()
+}
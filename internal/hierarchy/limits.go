@@ -0,0 +1,96 @@
+package hierarchy
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/IAMSamuelRodda/mcp-proxy/internal/config"
+)
+
+// ServerBusyError is returned when a server runs its maximum of concurrent calls and
+// its queue is full
+type ServerBusyError struct {
+	Server  string
+	Running int // Calls running on the server
+	Waiting int // Calls queued for a slot
+	Message string
+}
+
+func (e *ServerBusyError) Error() string {
+	return e.Message
+}
+
+// callLimiter caps the concurrent tool calls of a server and the calls queued for a slot
+type callLimiter struct {
+	slots    chan struct{}
+	maxQueue int // Most calls allowed to wait, -1 for no limit
+	waiting  int
+	mu       sync.Mutex
+}
+
+// callLimits returns the concurrency limit and queue size of a server, 0 concurrent
+// calls means no limit and a queue of -1 means no queue limit
+func callLimits(cfg *config.MCPClientConfigV2) (int, int) {
+	if cfg == nil || cfg.Options == nil || cfg.Options.MaxConcurrentCalls.OrElse(0) <= 0 {
+		return 0, -1
+	}
+	maxQueue := -1
+	if cfg.Options.MaxQueue.Present() {
+		maxQueue = max(cfg.Options.MaxQueue.OrElse(0), 0)
+	}
+	return cfg.Options.MaxConcurrentCalls.OrElse(0), maxQueue
+}
+
+// AcquireCall waits for a slot to call a tool on a server and returns the function
+// that gives the slot back. Servers without maxConcurrentCalls never wait
+// Waiting stops with ctx; a full queue fails right away with a ServerBusyError
+func (r *ServerRegistry) AcquireCall(ctx context.Context, serverName string) (func(), error) {
+	r.mu.Lock()
+	maxCalls, maxQueue := callLimits(r.serverConfigs[serverName])
+	if maxCalls <= 0 {
+		r.mu.Unlock()
+		return func() {}, nil
+	}
+	// Limits changed by a reload apply to new calls, running ones release the old slots
+	l, exists := r.limiters[serverName]
+	if !exists || cap(l.slots) != maxCalls || l.maxQueue != maxQueue {
+		l = &callLimiter{slots: make(chan struct{}, maxCalls), maxQueue: maxQueue}
+		r.limiters[serverName] = l
+	}
+	r.mu.Unlock()
+
+	release := func() { <-l.slots }
+	select {
+	case l.slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	l.mu.Lock()
+	if l.maxQueue >= 0 && l.waiting >= l.maxQueue {
+		waiting := l.waiting
+		l.mu.Unlock()
+		return nil, &ServerBusyError{
+			Server:  serverName,
+			Running: maxCalls,
+			Waiting: waiting,
+			Message: fmt.Sprintf("Server '%s' is busy: %d calls running and %d waiting, the most it allows. Try again shortly.",
+				serverName, maxCalls, waiting),
+		}
+	}
+	l.waiting++
+	l.mu.Unlock()
+	defer func() {
+		l.mu.Lock()
+		l.waiting--
+		l.mu.Unlock()
+	}()
+
+	select {
+	case l.slots <- struct{}{}:
+		return release, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("gave up waiting for a call slot on %s: %w", serverName, ctx.Err())
+	}
+}
@@ -0,0 +1,120 @@
+package hierarchy
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// PinnedTool is a hierarchy tool exposed directly as an MCP tool, next to the meta-tools
+type PinnedTool struct {
+	Name     string // MCP tool name: the tool's own name, prefixed when it collides
+	ToolPath string // Resolved hierarchy path, called through HandleExecuteTool
+	Tool     mcp.Tool
+}
+
+// PinnedTools resolves tool paths into MCP tool definitions
+// Names that collide with each other or with a reserved name (the meta-tools) are
+// prefixed with the server name, or with the full tool path if that still collides.
+// Paths that don't resolve are skipped and returned as errors.
+func (h *Hierarchy) PinnedTools(toolPaths []string, reserved []string) ([]PinnedTool, []error) {
+	type candidate struct {
+		name       string
+		toolPath   string
+		serverName string
+		toolDef    *ToolDefinition
+	}
+
+	var candidates []candidate
+	var errs []error
+	seenPaths := make(map[string]bool)
+	nameCounts := make(map[string]int)
+	for _, toolPath := range toolPaths {
+		toolDef, serverName, resolvedPath, err := h.resolveToolPath(toolPath)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("pinned tool %s: %w", toolPath, err))
+			continue
+		}
+		resolvedPath = h.canonicalToolPath(toolDef, resolvedPath)
+		if seenPaths[resolvedPath] {
+			continue
+		}
+		seenPaths[resolvedPath] = true
+
+		name := resolvedPath[strings.LastIndex(resolvedPath, ".")+1:]
+		candidates = append(candidates, candidate{name: name, toolPath: resolvedPath, serverName: serverName, toolDef: toolDef})
+		nameCounts[name]++
+	}
+
+	taken := make(map[string]bool, len(reserved)+len(candidates))
+	for _, name := range reserved {
+		taken[name] = true
+	}
+
+	pinned := make([]PinnedTool, 0, len(candidates))
+	for _, c := range candidates {
+		name := c.name
+		if nameCounts[name] > 1 || taken[name] {
+			prefix := c.serverName
+			if prefix == "" {
+				prefix = strings.SplitN(c.toolPath, ".", 2)[0]
+			}
+			name = mcpToolName(prefix + "_" + c.name)
+			if taken[name] {
+				name = mcpToolName(c.toolPath)
+			}
+		}
+		if taken[name] {
+			errs = append(errs, fmt.Errorf("pinned tool %s: name %s is already taken", c.toolPath, name))
+			continue
+		}
+		taken[name] = true
+
+		pinned = append(pinned, PinnedTool{
+			Name:     name,
+			ToolPath: c.toolPath,
+			Tool:     c.toolDef.mcpTool(name),
+		})
+	}
+	return pinned, errs
+}
+
+// mcpTool builds the MCP definition of a tool under the given name
+// The output schema is left out: results may be paged, which drops structured content
+func (t *ToolDefinition) mcpTool(name string) mcp.Tool {
+	tool := mcp.Tool{
+		Name:        name,
+		Description: t.Description,
+	}
+
+	schema := map[string]interface{}{"type": "object"}
+	if t.InputSchema != nil {
+		schema = t.VisibleInputSchema()
+	}
+	if raw, err := json.Marshal(schema); err == nil {
+		tool.RawInputSchema = raw
+	} else {
+		tool.InputSchema = mcp.ToolInputSchema{Type: "object"}
+	}
+
+	// Annotations use the MCP field names, so they convert through JSON
+	if raw, err := json.Marshal(t.Annotations); err == nil {
+		_ = json.Unmarshal(raw, &tool.Annotations)
+	}
+	if tool.Annotations.Title == "" {
+		tool.Annotations.Title = t.Title
+	}
+	return tool
+}
+
+// mcpToolName turns a tool path into a valid MCP tool name
+func mcpToolName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-' {
+			return r
+		}
+		return '_'
+	}, s)
+}
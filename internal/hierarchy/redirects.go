@@ -0,0 +1,88 @@
+package hierarchy
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// maxRedirectHops bounds redirect chains (a -> b -> c), which also stops redirect cycles
+const maxRedirectHops = 5
+
+// buildRedirects collects category aliases, category redirects and tool aliases
+// into a single old path -> new path map consulted when a path doesn't resolve
+func (h *Hierarchy) buildRedirects() {
+	h.redirects = make(map[string]string)
+
+	add := func(oldPath, newPath, source string) {
+		oldPath = strings.Trim(oldPath, ".")
+		newPath = strings.Trim(newPath, ".")
+		if oldPath == "" || newPath == "" || oldPath == newPath {
+			return
+		}
+		if existing, ok := h.redirects[oldPath]; ok && existing != newPath {
+			log.Printf("Warning: conflicting redirects for %s (%s and %s), declared in %s", oldPath, existing, newPath, source)
+			// Keep the choice deterministic regardless of load order
+			if existing < newPath {
+				return
+			}
+		}
+		h.redirects[oldPath] = newPath
+	}
+
+	for nodePath, node := range h.nodes {
+		if nodePath == "/" {
+			continue // Alias of the root node
+		}
+		for _, alias := range node.Aliases {
+			add(alias, nodePath, nodePath)
+		}
+		for oldPath, newPath := range node.Redirects {
+			if nodePath != "" {
+				oldPath = nodePath + "." + oldPath
+			}
+			add(oldPath, newPath, nodePath)
+		}
+		for toolName, toolDef := range node.Tools {
+			for _, alias := range toolDef.Aliases {
+				add(alias, toolPathFor(nodePath, toolName), nodePath)
+			}
+		}
+	}
+}
+
+// followRedirect rewrites a path using the longest matching redirect
+// A redirect for a category also applies to everything below it,
+// e.g., "github" -> "dev.github" turns "github.create_issue" into "dev.github.create_issue"
+// Caller must hold h.mu
+func (h *Hierarchy) followRedirect(path string) (string, bool) {
+	for prefix := path; prefix != ""; {
+		if target, ok := h.redirects[prefix]; ok {
+			return target + path[len(prefix):], true
+		}
+		idx := strings.LastIndex(prefix, ".")
+		if idx == -1 {
+			break
+		}
+		prefix = prefix[:idx]
+	}
+	return "", false
+}
+
+// deprecationNote tells the model that a path only works through a redirect
+func deprecationNote(oldPath, newPath string) string {
+	return fmt.Sprintf("'%s' is a deprecated path that was moved to '%s'. Use the new path in future calls.", oldPath, newPath)
+}
+
+// withDeprecationNote appends a deprecation note to a tool result called through an old path
+// The note is added as the last content item so the tool's own output stays first
+func withDeprecationNote(result *mcp.CallToolResult, oldPath, newPath string) *mcp.CallToolResult {
+	if result == nil {
+		return nil
+	}
+	noted := *result
+	noted.Content = append(append([]mcp.Content{}, result.Content...), mcp.NewTextContent("Note: "+deprecationNote(oldPath, newPath)))
+	return &noted
+}
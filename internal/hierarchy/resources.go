@@ -0,0 +1,131 @@
+package hierarchy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/IAMSamuelRodda/mcp-proxy/internal/client"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// ResourceURIPrefix namespaces downstream resources so URIs from different servers can't collide
+// e.g., "file:///tmp/a.txt" on server "filesystem" -> "mcp-proxy://filesystem/file:///tmp/a.txt"
+const ResourceURIPrefix = "mcp-proxy://"
+
+// PromptNameSeparator joins the server name and the downstream prompt name
+// e.g., prompt "review" on server "github" -> "github__review"
+const PromptNameSeparator = "__"
+
+// NamespaceResourceURI returns the proxy URI for a downstream resource URI (or URI template)
+func NamespaceResourceURI(serverName, uri string) string {
+	return ResourceURIPrefix + serverName + "/" + uri
+}
+
+// ParseResourceURI splits a proxy URI into the server name and the original downstream URI
+func ParseResourceURI(uri string) (string, string, error) {
+	rest, ok := strings.CutPrefix(uri, ResourceURIPrefix)
+	if !ok {
+		return "", "", fmt.Errorf("resource URI must start with %s: %s", ResourceURIPrefix, uri)
+	}
+	serverName, original, ok := strings.Cut(rest, "/")
+	if !ok || serverName == "" || original == "" {
+		return "", "", fmt.Errorf("resource URI must have the form %s<server>/<uri>: %s", ResourceURIPrefix, uri)
+	}
+	return serverName, original, nil
+}
+
+// NamespacePromptName returns the proxy name for a downstream prompt
+func NamespacePromptName(serverName, promptName string) string {
+	return serverName + PromptNameSeparator + promptName
+}
+
+// HandleReadResource routes a resources/read for a namespaced URI to the owning server
+// The server is loaded on demand, and returned content URIs are namespaced again
+func HandleReadResource(ctx context.Context, registry *ServerRegistry, uri string) ([]mcp.ResourceContents, error) {
+	serverName, original, err := ParseResourceURI(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	mcpClient, err := getEnabledServer(ctx, registry, serverName)
+	if err != nil {
+		return nil, err
+	}
+
+	readCtx, cancel := context.WithTimeout(ctx, registry.CallTimeout(serverName))
+	defer cancel()
+
+	start := time.Now()
+	readRequest := mcp.ReadResourceRequest{}
+	readRequest.Params.URI = original
+	result, err := mcpClient.ReadResource(readCtx, readRequest)
+	if err != nil {
+		log.Printf("Resource read failed for %s on %s after %v: %v", original, serverName, time.Since(start), err)
+		return nil, fmt.Errorf("failed to read resource %s: %w", original, err)
+	}
+	log.Printf("Read resource %s from %s in %v", original, serverName, time.Since(start))
+
+	contents := make([]mcp.ResourceContents, len(result.Contents))
+	for i, content := range result.Contents {
+		contents[i] = namespaceResourceContents(serverName, content)
+	}
+	return contents, nil
+}
+
+// HandleGetPrompt routes a prompts/get for a downstream prompt to the owning server
+func HandleGetPrompt(ctx context.Context, registry *ServerRegistry, serverName, promptName string, arguments map[string]string) (*mcp.GetPromptResult, error) {
+	mcpClient, err := getEnabledServer(ctx, registry, serverName)
+	if err != nil {
+		return nil, err
+	}
+
+	promptCtx, cancel := context.WithTimeout(ctx, registry.CallTimeout(serverName))
+	defer cancel()
+
+	promptRequest := mcp.GetPromptRequest{}
+	promptRequest.Params.Name = promptName
+	promptRequest.Params.Arguments = arguments
+	result, err := mcpClient.GetPrompt(promptCtx, promptRequest)
+	if err != nil {
+		log.Printf("Prompt %s failed on %s: %v", promptName, serverName, err)
+		return nil, fmt.Errorf("failed to get prompt %s: %w", promptName, err)
+	}
+	return result, nil
+}
+
+// getEnabledServer loads a server through the registry unless its circuit breaker is open
+func getEnabledServer(ctx context.Context, registry *ServerRegistry, serverName string) (*client.Client, error) {
+	if err := registry.CheckEnabled(serverName); err != nil {
+		return nil, err
+	}
+
+	mcpClient, err := registry.GetOrLoadServer(ctx, serverName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MCP client: %w", err)
+	}
+	return mcpClient, nil
+}
+
+// namespaceResourceContents rewrites the URI of returned contents into the proxy namespace
+func namespaceResourceContents(serverName string, content mcp.ResourceContents) mcp.ResourceContents {
+	switch c := content.(type) {
+	case mcp.TextResourceContents:
+		c.URI = NamespaceResourceURI(serverName, c.URI)
+		return c
+	case *mcp.TextResourceContents:
+		namespaced := *c
+		namespaced.URI = NamespaceResourceURI(serverName, c.URI)
+		return namespaced
+	case mcp.BlobResourceContents:
+		c.URI = NamespaceResourceURI(serverName, c.URI)
+		return c
+	case *mcp.BlobResourceContents:
+		namespaced := *c
+		namespaced.URI = NamespaceResourceURI(serverName, c.URI)
+		return namespaced
+	}
+	return content
+}
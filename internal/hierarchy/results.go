@@ -0,0 +1,226 @@
+package hierarchy
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// DefaultResultTTL is how long an oversized result stays available to fetch_result_page
+const DefaultResultTTL = 10 * time.Minute
+
+// maxStoredResults caps the in-memory result store, the oldest result is evicted first
+const maxStoredResults = 100
+
+// storedResult is the full text of an oversized tool result
+type storedResult struct {
+	text     string
+	pageSize int
+	expires  time.Time
+}
+
+// ResultStore keeps oversized tool results in memory so they can be paged through
+type ResultStore struct {
+	ttl     time.Duration
+	entries map[string]*storedResult
+	order   []string // Handles, oldest first
+	mu      sync.Mutex
+}
+
+// NewResultStore creates a result store whose entries expire after ttl
+func NewResultStore(ttl time.Duration) *ResultStore {
+	if ttl <= 0 {
+		ttl = DefaultResultTTL
+	}
+	return &ResultStore{
+		ttl:     ttl,
+		entries: make(map[string]*storedResult),
+	}
+}
+
+// SetTTL changes how long newly stored results stay available
+func (s *ResultStore) SetTTL(ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ttl = ttl
+}
+
+// ResultPage is a slice of a stored result returned by fetch_result_page
+type ResultPage struct {
+	Handle     string
+	Text       string
+	Offset     int
+	NextOffset int // 0 when this is the last page
+	TotalBytes int
+	Expires    time.Time
+}
+
+// put stores text and returns its handle
+func (s *ResultStore) put(text string, pageSize int) string {
+	handle := newResultHandle()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.evictExpired()
+	for len(s.order) >= maxStoredResults {
+		delete(s.entries, s.order[0])
+		s.order = s.order[1:]
+	}
+
+	s.entries[handle] = &storedResult{
+		text:     text,
+		pageSize: pageSize,
+		expires:  time.Now().Add(s.ttl),
+	}
+	s.order = append(s.order, handle)
+	return handle
+}
+
+// Page returns the page of a stored result starting at offset
+// Pages are as large as the maxResultBytes limit the result was stored under
+func (s *ResultStore) Page(handle string, offset int) (*ResultPage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.evictExpired()
+	entry, ok := s.entries[handle]
+	if !ok {
+		return nil, fmt.Errorf("result handle not found or expired: %s. Call the tool again to get a new handle.", handle)
+	}
+	if offset < 0 || offset >= len(entry.text) {
+		return nil, fmt.Errorf("offset %d is out of range, the result has %d bytes", offset, len(entry.text))
+	}
+	text, next := sliceText(entry.text, offset, entry.pageSize)
+	return &ResultPage{
+		Handle:     handle,
+		Text:       text,
+		Offset:     offset,
+		NextOffset: next,
+		TotalBytes: len(entry.text),
+		Expires:    entry.expires,
+	}, nil
+}
+
+// evictExpired drops expired entries. Caller must hold s.mu
+func (s *ResultStore) evictExpired() {
+	now := time.Now()
+	kept := s.order[:0]
+	for _, handle := range s.order {
+		if entry := s.entries[handle]; entry != nil && now.Before(entry.expires) {
+			kept = append(kept, handle)
+		} else {
+			delete(s.entries, handle)
+		}
+	}
+	s.order = kept
+}
+
+// newResultHandle returns a random, unguessable handle for a stored result
+func newResultHandle() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand doesn't fail on supported platforms, fall back to the clock
+		return fmt.Sprintf("res_%x", time.Now().UnixNano())
+	}
+	return "res_" + hex.EncodeToString(b)
+}
+
+// sliceText cuts up to maxBytes from text at offset without splitting a UTF-8 character
+// Returns the slice and the offset of the next page, 0 when the end was reached
+func sliceText(text string, offset, maxBytes int) (string, int) {
+	end := offset + maxBytes
+	if end >= len(text) {
+		return text[offset:], 0
+	}
+	for end > offset && !utf8.RuneStart(text[end]) {
+		end--
+	}
+	if end == offset {
+		// maxBytes is smaller than a single character, return it whole
+		_, size := utf8.DecodeRuneInString(text[offset:])
+		end = offset + size
+	}
+	if end >= len(text) {
+		return text[offset:], 0
+	}
+	return text[offset:end], end
+}
+
+// limitResult pages a tool result whose size exceeds maxBytes
+// The text content is stored in full and replaced by its first page plus a note
+// with the handle; non-text content is kept as is, structured content is dropped
+func (s *ResultStore) limitResult(toolPath string, result *mcp.CallToolResult, maxBytes int) *mcp.CallToolResult {
+	if result == nil || maxBytes <= 0 {
+		return result
+	}
+	size, err := json.Marshal(result)
+	if err != nil || len(size) <= maxBytes {
+		return result
+	}
+
+	var texts []string
+	var other []mcp.Content
+	for _, content := range result.Content {
+		if text, ok := mcp.AsTextContent(content); ok {
+			texts = append(texts, text.Text)
+		} else {
+			other = append(other, content)
+		}
+	}
+	fullText := strings.Join(texts, "\n")
+	if len(fullText) <= maxBytes {
+		return result // Too large because of non-text content, which can't be paged
+	}
+
+	handle := s.put(fullText, maxBytes)
+	page, err := s.Page(handle, 0)
+	if err != nil {
+		return result
+	}
+	log.Printf("Result of %s is %d bytes, paging with handle %s", toolPath, len(fullText), handle)
+
+	limited := &mcp.CallToolResult{
+		Result:  result.Result,
+		IsError: result.IsError,
+	}
+	limited.Content = append(limited.Content, mcp.NewTextContent(page.Text))
+	limited.Content = append(limited.Content, other...)
+	limited.Content = append(limited.Content, mcp.NewTextContent(pageNote(page)))
+	return limited
+}
+
+// HandleFetchResultPage handles the fetch_result_page meta-tool
+func (s *ResultStore) HandleFetchResultPage(handle string, offset int) (*mcp.CallToolResult, error) {
+	page, err := s.Page(handle, offset)
+	if err != nil {
+		return nil, err
+	}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.NewTextContent(page.Text),
+			mcp.NewTextContent(pageNote(page)),
+		},
+	}, nil
+}
+
+// pageNote tells the model which part of the result it got and how to get the rest
+func pageNote(page *ResultPage) string {
+	end := page.Offset + len(page.Text)
+	if page.NextOffset == 0 {
+		return fmt.Sprintf("[Result page: bytes %d-%d of %d, end of result]", page.Offset, end, page.TotalBytes)
+	}
+	return fmt.Sprintf("[Result truncated: bytes %d-%d of %d shown. Call fetch_result_page with handle %q and offset %d for the next page. Available until %s]",
+		page.Offset, end, page.TotalBytes, page.Handle, page.NextOffset, page.Expires.Format(time.RFC3339))
+}
@@ -0,0 +1,218 @@
+package hierarchy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Default and maximum number of results returned by search_tools
+const (
+	DefaultSearchLimit = 10
+	MaxSearchLimit     = 50
+)
+
+// Score weights for the different fields a search term can match
+const (
+	scoreNameExact   = 10
+	scoreNameContain = 5
+	scorePathSegment = 2
+	scoreDescription = 3
+	scoreProperty    = 2
+	scoreOverview    = 1
+)
+
+// ToolSearchResult is a single ranked match returned by HandleSearchTools
+type ToolSearchResult struct {
+	ToolPath    string `json:"tool_path"`
+	Description string `json:"description,omitempty"`
+	Server      string `json:"server,omitempty"`
+	Score       int    `json:"score"`
+}
+
+// HandleSearchTools handles the search_tools meta-tool
+// Scores every tool in the hierarchy against the query terms and returns the
+// highest-ranked matches, so the model can find a tool without walking categories
+func (h *Hierarchy) HandleSearchTools(query string, limit int) (map[string]interface{}, error) {
+	terms := tokenizeQuery(query)
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("query must contain at least one word")
+	}
+
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+	if limit > MaxSearchLimit {
+		limit = MaxSearchLimit
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var matches []ToolSearchResult
+	for nodePath, node := range h.nodes {
+		if nodePath == "/" {
+			continue // Alias of the root node
+		}
+
+		// Overviews of the node and all its ancestors describe the tool's context
+		overviews := h.categoryOverviews(nodePath)
+
+		for toolName, toolDef := range node.Tools {
+			toolPath := toolPathFor(nodePath, toolName)
+			score := scoreTool(terms, toolName, toolPath, toolDef, overviews)
+			if score == 0 {
+				continue
+			}
+			matches = append(matches, ToolSearchResult{
+				ToolPath:    toolPath,
+				Description: toolDef.Description,
+				Server:      toolDef.Server,
+				Score:       score,
+			})
+		}
+	}
+
+	// Highest score first, path as a stable tie-breaker
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].ToolPath < matches[j].ToolPath
+	})
+
+	total := len(matches)
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	if matches == nil {
+		matches = []ToolSearchResult{}
+	}
+
+	response := map[string]interface{}{
+		"query":         query,
+		"results":       matches,
+		"total_matches": total,
+	}
+
+	// Cut the lowest ranked results that don't fit in the listing budget
+	if budget := h.listingBudget; budget > 0 && h.EstimateTokens(response) > budget {
+		more := map[string]interface{}{
+			"results": 0,
+			"hint":    "Results cut to fit the token budget. Use more specific keywords to find the rest.",
+		}
+		used := h.EstimateTokens(response) + h.EstimateTokens(map[string]interface{}{"more_available": more})
+		for len(matches) > 0 && used > budget {
+			used -= h.EstimateTokens(matches[len(matches)-1])
+			matches = matches[:len(matches)-1]
+			more["results"] = more["results"].(int) + 1
+		}
+		response["results"] = matches
+		response["more_available"] = more
+	}
+	return response, nil
+}
+
+// categoryOverviews collects the lowercased overviews of a node and its ancestors
+// The root overview is skipped since it summarizes every category
+// Caller must hold h.mu
+func (h *Hierarchy) categoryOverviews(nodePath string) []string {
+	var overviews []string
+	for t := h.tree[nodePath]; t != nil && t.path != ""; t = t.parent {
+		if t.node.Overview != "" {
+			overviews = append(overviews, strings.ToLower(t.node.Overview))
+		}
+	}
+	return overviews
+}
+
+// scoreTool returns the relevance of a tool for the given query terms
+// Every term is scored independently and the scores are summed
+func scoreTool(terms []string, toolName, toolPath string, toolDef *ToolDefinition, overviews []string) int {
+	name := strings.ToLower(toolName)
+	description := strings.ToLower(toolDef.Description)
+	segments := strings.Split(strings.ToLower(toolPath), ".")
+	properties := schemaPropertyNames(toolDef.VisibleInputSchema())
+
+	score := 0
+	for _, term := range terms {
+		switch {
+		case name == term:
+			score += scoreNameExact
+		case strings.Contains(name, term):
+			score += scoreNameContain
+		}
+
+		// Category names along the path (excluding the tool itself)
+		for _, segment := range segments[:len(segments)-1] {
+			if strings.Contains(segment, term) {
+				score += scorePathSegment
+				break
+			}
+		}
+
+		if strings.Contains(description, term) {
+			score += scoreDescription
+		}
+
+		for _, property := range properties {
+			if strings.Contains(property, term) {
+				score += scoreProperty
+				break
+			}
+		}
+
+		for _, overview := range overviews {
+			if strings.Contains(overview, term) {
+				score += scoreOverview
+				break
+			}
+		}
+	}
+	return score
+}
+
+// schemaPropertyNames returns the lowercased top-level property names of an input schema
+func schemaPropertyNames(schema map[string]interface{}) []string {
+	props, ok := schema["properties"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	names := make([]string, 0, len(props))
+	for name := range props {
+		names = append(names, strings.ToLower(name))
+	}
+	return names
+}
+
+// tokenizeQuery splits a free-text query into lowercase terms
+// Underscores are kept so snake_case tool names can be searched verbatim
+func tokenizeQuery(query string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+	})
+
+	seen := make(map[string]bool, len(fields))
+	terms := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if !seen[field] {
+			seen[field] = true
+			terms = append(terms, field)
+		}
+	}
+	return terms
+}
+
+// toolPathFor builds the tool path that execute_tool accepts for a tool in a node
+// In the flat structure the node path already ends with the tool name
+// e.g., node "everything.echo" with tool "echo" -> "everything.echo"
+func toolPathFor(nodePath, toolName string) string {
+	if nodePath == "" {
+		return toolName
+	}
+	if nodePath == toolName || strings.HasSuffix(nodePath, "."+toolName) {
+		return nodePath
+	}
+	return nodePath + "." + toolName
+}
@@ -0,0 +1,219 @@
+package hierarchy
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/IAMSamuelRodda/mcp-proxy/internal/config"
+)
+
+// declaredServer is an mcp_server block found in a hierarchy node
+type declaredServer struct {
+	nodePath string
+	ref      *MCPServerRef
+}
+
+// collectServers gathers the mcp_server blocks of all nodes, keyed by server name
+// A block without a name is named after its node. When two nodes declare the same
+// server differently, the node with the smallest path wins and a warning is logged.
+func (h *Hierarchy) collectServers() {
+	h.servers = make(map[string]declaredServer)
+
+	nodePaths := make([]string, 0, len(h.nodes))
+	for nodePath, node := range h.nodes {
+		if nodePath != "/" && node.MCPServer != nil {
+			nodePaths = append(nodePaths, nodePath)
+		}
+	}
+	sort.Strings(nodePaths)
+
+	for _, nodePath := range nodePaths {
+		ref := h.nodes[nodePath].MCPServer
+		name := ref.Name
+		if name == "" {
+			name = nodePath[strings.LastIndex(nodePath, ".")+1:]
+		}
+		if name == "" {
+			log.Printf("Warning: mcp_server block in root node has no name, ignoring it")
+			continue
+		}
+		if existing, ok := h.servers[name]; ok {
+			if !sameServerDefinition(existing.ref.ToClientConfig(), ref.ToClientConfig()) {
+				log.Printf("Warning: server %s is declared differently in hierarchy nodes %s and %s, using %s",
+					name, existing.nodePath, nodePath, existing.nodePath)
+			}
+			continue
+		}
+		h.servers[name] = declaredServer{nodePath: nodePath, ref: ref}
+	}
+}
+
+// applyToolMappings sets maps_to from the tool_mappings of the tool's server
+// An explicit maps_to that differs from the tool name always wins over a mapping
+func (h *Hierarchy) applyToolMappings() {
+	for nodePath, node := range h.nodes {
+		if nodePath == "/" {
+			continue // Alias of the root node
+		}
+		for toolName, toolDef := range node.Tools {
+			declared, ok := h.servers[toolDef.Server]
+			if !ok || len(declared.ref.ToolMappings) == 0 || toolDef.MapsTo != toolName {
+				continue
+			}
+			// A mapping can be keyed by full tool path or by bare tool name
+			if mapped, ok := declared.ref.ToolMappings[toolPathFor(nodePath, toolName)]; ok {
+				toolDef.MapsTo = mapped
+			} else if mapped, ok := declared.ref.ToolMappings[toolName]; ok {
+				toolDef.MapsTo = mapped
+			}
+		}
+	}
+}
+
+// ServerConfigs returns client configs for the servers declared in hierarchy nodes
+// Per-server options are inherited from proxyOptions, and ${VAR} references are
+// expanded from the environment when expandEnv is set, like servers in the main config.
+// Variables that aren't set expand to "" and are returned as warnings
+func (h *Hierarchy) ServerConfigs(proxyOptions *config.OptionsV2, expandEnv bool) (map[string]*config.MCPClientConfigV2, []CheckIssue) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	configs := make(map[string]*config.MCPClientConfigV2, len(h.servers))
+	var issues []CheckIssue
+	for name, declared := range h.servers {
+		cfg := declared.ref.ToClientConfig()
+		if expandEnv {
+			for _, variable := range expandServerEnv(cfg) {
+				message := fmt.Sprintf("server %s uses ${%s}, which is not set and expands to an empty string", name, variable)
+				log.Printf("Warning: %s", message)
+				issues = append(issues, CheckIssue{
+					Severity: SeverityWarning,
+					Kind:     IssueUnsetVariable,
+					Path:     declared.nodePath,
+					Message:  message,
+				})
+			}
+		}
+		config.InheritProxyOptions(cfg, proxyOptions)
+		configs[name] = cfg
+	}
+	return configs, issues
+}
+
+// expandServerEnv expands environment variables in the fields that start or reach a server
+// and returns the names of the variables that aren't set, sorted
+func expandServerEnv(cfg *config.MCPClientConfigV2) []string {
+	unset := make(map[string]bool)
+	expand := func(s string) string {
+		return os.Expand(s, func(name string) string {
+			value, ok := os.LookupEnv(name)
+			if !ok {
+				unset[name] = true
+			}
+			return value
+		})
+	}
+
+	cfg.Command = expand(cfg.Command)
+	cfg.URL = expand(cfg.URL)
+	if len(cfg.Args) > 0 {
+		args := make([]string, len(cfg.Args))
+		for i, arg := range cfg.Args {
+			args[i] = expand(arg)
+		}
+		cfg.Args = args
+	}
+	cfg.Env = expandValues(cfg.Env, expand)
+	cfg.Headers = expandValues(cfg.Headers, expand)
+
+	names := make([]string, 0, len(unset))
+	for name := range unset {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// expandValues returns a copy of m with expand applied to its values
+func expandValues(m map[string]string, expand func(string) string) map[string]string {
+	if len(m) == 0 {
+		return m
+	}
+	expanded := make(map[string]string, len(m))
+	for k, v := range m {
+		expanded[k] = expand(v)
+	}
+	return expanded
+}
+
+// MergeServerConfigs adds servers declared in the hierarchy to the registry
+// Servers from the main config always win; a hierarchy server with the same name but
+// a different definition is reported as a conflict. Servers that came from the
+// hierarchy earlier are updated, taking effect the next time they are started.
+func (r *ServerRegistry) MergeServerConfigs(hierarchyConfigs map[string]*config.MCPClientConfigV2) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := make([]string, 0, len(hierarchyConfigs))
+	for name := range hierarchyConfigs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var conflicts []string
+	for _, name := range names {
+		cfg := hierarchyConfigs[name]
+		existing, exists := r.serverConfigs[name]
+		switch {
+		case !exists:
+			log.Printf("Registered MCP server %s from hierarchy", name)
+		case !r.hierarchyServers[name]:
+			if !sameServerDefinition(existing, cfg) {
+				conflict := fmt.Sprintf("server %s is declared in the hierarchy and in mcpServers with different settings, using mcpServers", name)
+				log.Printf("Warning: %s", conflict)
+				conflicts = append(conflicts, conflict)
+			}
+			continue
+		case sameServerDefinition(existing, cfg):
+			continue
+		default:
+			log.Printf("Updated MCP server %s from hierarchy (applies on next start)", name)
+		}
+		r.serverConfigs[name] = cfg
+		r.hierarchyServers[name] = true
+	}
+	return conflicts
+}
+
+// sameServerDefinition compares how two configs start or reach a server, ignoring options
+func sameServerDefinition(a, b *config.MCPClientConfigV2) bool {
+	return effectiveTransport(a) == effectiveTransport(b) &&
+		a.Command == b.Command &&
+		sameValues(a.Args, b.Args) &&
+		sameValues(a.Env, b.Env) &&
+		a.URL == b.URL &&
+		sameValues(a.Headers, b.Headers)
+}
+
+// effectiveTransport returns the transport a config resolves to, as in config.ParseMCPClientConfigV2
+func effectiveTransport(cfg *config.MCPClientConfigV2) config.MCPClientType {
+	if cfg.Command != "" {
+		return config.MCPClientTypeStdio
+	}
+	if cfg.TransportType == config.MCPClientTypeStreamable {
+		return config.MCPClientTypeStreamable
+	}
+	return config.MCPClientTypeSSE
+}
+
+// sameValues compares slices or maps, treating nil and empty as equal
+func sameValues(a, b interface{}) bool {
+	if reflect.ValueOf(a).Len() == 0 && reflect.ValueOf(b).Len() == 0 {
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
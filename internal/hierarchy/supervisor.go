@@ -0,0 +1,179 @@
+package hierarchy
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/IAMSamuelRodda/mcp-proxy/internal/client"
+	"github.com/IAMSamuelRodda/mcp-proxy/internal/config"
+)
+
+// Defaults of the stdio server supervisor
+const (
+	DefaultPingIntervalMs  = 30000
+	DefaultMaxRestarts     = 5
+	DefaultRestartWindowMs = 600000
+
+	// Consecutive failed pings before an unresponsive server is killed
+	maxPingFailures = 3
+)
+
+// ProcessStatus is what the supervisor knows about a stdio server's process
+type ProcessStatus struct {
+	Restarts   int       `json:"restarts"`               // Restarts after the process exited, since the proxy started
+	LastExit   string    `json:"last_exit,omitempty"`    // How the process last exited, e.g. "exit status 1"
+	LastExitAt time.Time `json:"last_exit_at,omitempty"` // When it last exited
+}
+
+// serverProcess is the supervisor's record of a stdio server
+type serverProcess struct {
+	ProcessStatus
+	recent []time.Time // Restarts within the restart window
+	timer  *time.Timer // Pending restart
+}
+
+// supervise watches a stdio server until its client is closed: it pings the server,
+// kills it when it stops answering and restarts it when the process exits
+func (r *ServerRegistry) supervise(serverName string, c *client.Client) {
+	r.mu.RLock()
+	interval := pingInterval(r.serverConfigs[serverName])
+	r.mu.RUnlock()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	failures := 0
+	killed := false
+	for {
+		select {
+		case <-c.Exited():
+			r.processExited(serverName, c, killed)
+			return
+		case <-ticker.C:
+			pingCtx, cancel := context.WithTimeout(context.Background(), interval)
+			err := c.GetClient().Ping(pingCtx)
+			cancel()
+			if err == nil {
+				if failures > 0 {
+					log.Printf("<%s> MCP Ping recovered after %d failures", serverName, failures)
+				}
+				failures = 0
+				continue
+			}
+			// A server that handles one request at a time can't answer during a long call
+			if c.Busy() || killed {
+				continue
+			}
+			failures++
+			log.Printf("<%s> MCP Ping failed: %v (count=%d)", serverName, err, failures)
+			if failures >= maxPingFailures {
+				log.Printf("<%s> Not responding after %d pings, killing the process", serverName, failures)
+				killed = true
+				c.Kill()
+			}
+		}
+	}
+}
+
+// processExited records the exit of a supervised server and schedules its restart
+// Restarts back off like breaker retries; once the restart budget is spent the breaker opens
+func (r *ServerRegistry) processExited(serverName string, c *client.Client, killed bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed || r.clients[serverName] != c {
+		return // Closed by the proxy, not a crash
+	}
+	delete(r.clients, serverName)
+
+	p, exists := r.processes[serverName]
+	if !exists {
+		p = &serverProcess{}
+		r.processes[serverName] = p
+	}
+	now := time.Now()
+	p.LastExit = c.ExitStatus()
+	if killed {
+		p.LastExit = fmt.Sprintf("killed after %d failed pings (%s)", maxPingFailures, p.LastExit)
+	}
+	p.LastExitAt = now
+
+	cfg := r.serverConfigs[serverName]
+	maxRestarts, window := restartBudget(cfg)
+	recent := p.recent[:0]
+	for _, t := range p.recent {
+		if now.Sub(t) < window {
+			recent = append(recent, t)
+		}
+	}
+	p.recent = recent
+	if len(p.recent) >= maxRestarts {
+		log.Printf("Server %s exited: %s. Restarted %d times in %v, giving up", serverName, p.LastExit, len(p.recent), window)
+		r.tripBreaker(serverName, "CRASH_LOOP")
+		return
+	}
+
+	// The first restart is immediate, later ones within the window back off
+	var delay time.Duration
+	if len(p.recent) > 0 {
+		delay = retryDelay(cfg, len(p.recent))
+	}
+	p.recent = append(p.recent, now)
+	p.Restarts++
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	p.timer = time.AfterFunc(delay, func() { r.restartServer(serverName) })
+	log.Printf("Server %s exited: %s. Restart %d in %v", serverName, p.LastExit, p.Restarts, delay)
+}
+
+// restartServer starts a supervised server again unless a call already did
+// A failed start opens the server's breaker through loadServer
+func (r *ServerRegistry) restartServer(serverName string) {
+	r.mu.RLock()
+	_, running := r.clients[serverName]
+	skip := r.closed || running
+	r.mu.RUnlock()
+	if skip {
+		return
+	}
+
+	log.Printf("Restarting MCP server %s", serverName)
+	if _, err := r.GetOrLoadServer(context.Background(), serverName); err != nil {
+		log.Printf("Restart of %s failed: %v", serverName, err)
+	}
+}
+
+// ProcessStatus returns the restart count and last exit of a supervised stdio server
+func (r *ServerRegistry) ProcessStatus(name string) ProcessStatus {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if p, exists := r.processes[name]; exists {
+		return p.ProcessStatus
+	}
+	return ProcessStatus{}
+}
+
+// pingInterval is how often the supervisor pings a stdio server
+func pingInterval(cfg *config.MCPClientConfigV2) time.Duration {
+	intervalMs := DefaultPingIntervalMs
+	if cfg != nil && cfg.Options != nil && cfg.Options.PingIntervalMs.OrElse(0) > 0 {
+		intervalMs = cfg.Options.PingIntervalMs.OrElse(DefaultPingIntervalMs)
+	}
+	return time.Duration(intervalMs) * time.Millisecond
+}
+
+// restartBudget is how many restarts of a stdio server are allowed within the window
+func restartBudget(cfg *config.MCPClientConfigV2) (int, time.Duration) {
+	maxRestarts, windowMs := DefaultMaxRestarts, DefaultRestartWindowMs
+	if cfg != nil && cfg.Options != nil {
+		if cfg.Options.MaxRestarts.OrElse(0) > 0 {
+			maxRestarts = cfg.Options.MaxRestarts.OrElse(DefaultMaxRestarts)
+		}
+		if cfg.Options.RestartWindowMs.OrElse(0) > 0 {
+			windowMs = cfg.Options.RestartWindowMs.OrElse(DefaultRestartWindowMs)
+		}
+	}
+	return maxRestarts, time.Duration(windowMs) * time.Millisecond
+}
@@ -0,0 +1,231 @@
+package hierarchy
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// DefaultCharsPerToken is the common ~4 characters per token heuristic
+const DefaultCharsPerToken = 4
+
+// TokenEstimator estimates how many tokens a text costs the model
+type TokenEstimator func(text string) int
+
+// CharsPerTokenEstimator counts one token per n characters, rounding up
+func CharsPerTokenEstimator(n int) TokenEstimator {
+	if n <= 0 {
+		n = DefaultCharsPerToken
+	}
+	return func(text string) int {
+		return (len(text) + n - 1) / n
+	}
+}
+
+// SetTokenEstimator replaces the chars/4 estimator used for token budgets and accounting
+func (h *Hierarchy) SetTokenEstimator(estimator TokenEstimator) {
+	if estimator == nil {
+		estimator = CharsPerTokenEstimator(DefaultCharsPerToken)
+	}
+	h.estimator.Store(&estimator)
+	h.fullExposure.Store(nil)
+}
+
+// SetListingBudget caps the estimated tokens of get_tools_in_category and search_tools
+// responses; entries past the budget are cut and counted under "more_available"
+func (h *Hierarchy) SetListingBudget(maxTokens int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.listingBudget = maxTokens
+}
+
+// listingBudgetFor combines a per-call token budget with the configured listing budget
+// The tighter one wins, 0 means no budget. Caller must hold h.mu
+func (h *Hierarchy) listingBudgetFor(maxTokens int) int {
+	if h.listingBudget > 0 && (maxTokens <= 0 || h.listingBudget < maxTokens) {
+		return h.listingBudget
+	}
+	return maxTokens
+}
+
+// EstimateTokens estimates the tokens a value costs once serialized as JSON for
+// the model; strings are counted as they are
+func (h *Hierarchy) EstimateTokens(v interface{}) int {
+	estimator := CharsPerTokenEstimator(DefaultCharsPerToken)
+	if p := h.estimator.Load(); p != nil {
+		estimator = *p
+	}
+
+	if text, ok := v.(string); ok {
+		return estimator(text)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return 0
+	}
+	return estimator(string(data))
+}
+
+// fullExposureCost is the cached result of FullExposureTokens
+type fullExposureCost struct {
+	tokens int
+	tools  int
+}
+
+// FullExposureTokens estimates what listing every hierarchy tool as a direct MCP
+// tool would cost, the baseline the proxy's context savings are measured against
+func (h *Hierarchy) FullExposureTokens() (tokens int, tools int) {
+	if cost := h.fullExposure.Load(); cost != nil {
+		return cost.tokens, cost.tools
+	}
+
+	h.mu.RLock()
+	cost := &fullExposureCost{}
+	for name, entries := range h.toolIndex {
+		for _, entry := range entries {
+			cost.tokens += h.EstimateTokens(entry.tool.mcpTool(name))
+			cost.tools++
+		}
+	}
+	h.mu.RUnlock()
+
+	h.fullExposure.Store(cost)
+	return cost.tokens, cost.tools
+}
+
+// trimListing drops entries from the end of a get_tools_in_category response until it
+// fits in maxTokens, tools before children, and says what was left out
+// Caller must hold h.mu
+func (h *Hierarchy) trimListing(response map[string]interface{}, maxTokens int) {
+	used := h.EstimateTokens(response)
+	if used <= maxTokens {
+		return
+	}
+
+	more := map[string]interface{}{
+		"tools":    0,
+		"children": 0,
+		"hint":     "Listing cut to fit the token budget. List a subcategory or use search_tools to find the rest.",
+	}
+	used += h.EstimateTokens(map[string]interface{}{"more_available": more})
+
+	// Removing an entry saves about what it costs on its own
+	for _, field := range []string{"tools", "children"} {
+		entries, _ := response[field].(map[string]interface{})
+		names := make([]string, 0, len(entries))
+		for name := range entries {
+			names = append(names, name)
+		}
+		sort.Sort(sort.Reverse(sort.StringSlice(names)))
+
+		for _, name := range names {
+			if used <= maxTokens {
+				break
+			}
+			used -= h.EstimateTokens(map[string]interface{}{name: entries[name]})
+			delete(entries, name)
+			more[field] = more[field].(int) + 1
+		}
+	}
+	response["more_available"] = more
+}
+
+// TokenUsage is a snapshot of the tokens the proxy has put in the model's context
+type TokenUsage struct {
+	Definitions int // Tool definitions currently in tools/list: meta-tools and pinned tools
+	Responses   int // Discovery responses so far: listings, searches and tool descriptions
+	Calls       int // Number of discovery responses
+	ToolOutput  int // Results of tool calls, including batches and result pages
+	ToolCalls   int // Number of tool call results
+}
+
+// Spent is the total estimated cost of the proxy so far; tool output is left out
+// since it costs the same without the proxy
+func (u TokenUsage) Spent() int {
+	return u.Definitions + u.Responses
+}
+
+// TokenTally keeps a running total of the tokens the proxy costs the model
+// Results of tool calls are tallied apart: they cost the same without the proxy
+type TokenTally struct {
+	definitions map[string]int // MCP tool name -> tokens of its definition
+	responses   int
+	calls       int
+	toolOutput  int
+	toolCalls   int
+	mu          sync.Mutex
+}
+
+func NewTokenTally() *TokenTally {
+	return &TokenTally{definitions: make(map[string]int)}
+}
+
+// SetDefinition records the cost of a tool definition registered on the MCP server
+func (t *TokenTally) SetDefinition(name string, tokens int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.definitions[name] = tokens
+}
+
+// RemoveDefinitions forgets the definitions of tools removed from the MCP server
+func (t *TokenTally) RemoveDefinitions(names ...string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, name := range names {
+		delete(t.definitions, name)
+	}
+}
+
+// AddResponse counts a discovery response
+func (t *TokenTally) AddResponse(tokens int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.responses += tokens
+	t.calls++
+}
+
+// AddToolOutput counts the result of a tool call
+func (t *TokenTally) AddToolOutput(tokens int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.toolOutput += tokens
+	t.toolCalls++
+}
+
+// Usage returns the current totals
+func (t *TokenTally) Usage() TokenUsage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.usage()
+}
+
+// usage builds the totals, caller must hold t.mu
+func (t *TokenTally) usage() TokenUsage {
+	u := TokenUsage{Responses: t.responses, Calls: t.calls, ToolOutput: t.toolOutput, ToolCalls: t.toolCalls}
+	for _, tokens := range t.definitions {
+		u.Definitions += tokens
+	}
+	return u
+}
+
+// Tokens returns the running token tally, kept across reloads
+func (h *Hierarchy) Tokens() *TokenTally {
+	return h.tokens
+}
+
+// TokenSummary describes the tally against the cost of exposing every tool directly
+func (h *Hierarchy) TokenSummary() string {
+	u := h.tokens.Usage()
+	fullTokens, fullTools := h.FullExposureTokens()
+
+	summary := fmt.Sprintf("spent ~%d tokens (%d in tool definitions, %d in %d responses) vs ~%d to expose all %d tools directly",
+		u.Spent(), u.Definitions, u.Responses, u.Calls, fullTokens, fullTools)
+	if fullTokens > 0 && u.Spent() < fullTokens {
+		summary += fmt.Sprintf(", %d%% saved", (fullTokens-u.Spent())*100/fullTokens)
+	}
+	if u.ToolCalls > 0 {
+		summary += fmt.Sprintf("; plus ~%d tokens in %d tool results, the same with or without the proxy", u.ToolOutput, u.ToolCalls)
+	}
+	return summary
+}
@@ -0,0 +1,70 @@
+package hierarchy
+
+import (
+	"sort"
+	"strings"
+)
+
+// MaxCategoryDepth caps how many levels get_tools_in_category expands in one call
+const MaxCategoryDepth = 5
+
+// treeNode is a node's position in the category tree, built once at load time
+// so listing a category doesn't have to scan every node in the hierarchy
+type treeNode struct {
+	path      string // Full dotted path, "" for root
+	name      string // Last path segment
+	node      *HierarchyNode
+	parent    *treeNode
+	children  []*treeNode // Sorted by name
+	toolNames []string    // Sorted names of the node's own tools
+}
+
+// buildTree links every node to its parent and sorts child and tool lists
+// A node whose parent category doesn't exist is not reachable from the root,
+// matching how categories have always been listed
+func (h *Hierarchy) buildTree() {
+	h.tree = make(map[string]*treeNode, len(h.nodes))
+	for nodePath, node := range h.nodes {
+		if nodePath == "/" {
+			continue // Alias of the root node
+		}
+
+		name := nodePath
+		if idx := strings.LastIndex(nodePath, "."); idx != -1 {
+			name = nodePath[idx+1:]
+		}
+
+		toolNames := make([]string, 0, len(node.Tools))
+		for toolName := range node.Tools {
+			toolNames = append(toolNames, toolName)
+		}
+		sort.Strings(toolNames)
+
+		h.tree[nodePath] = &treeNode{
+			path:      nodePath,
+			name:      name,
+			node:      node,
+			toolNames: toolNames,
+		}
+	}
+
+	for nodePath, t := range h.tree {
+		if nodePath == "" {
+			continue
+		}
+		parentPath := ""
+		if idx := strings.LastIndex(nodePath, "."); idx != -1 {
+			parentPath = nodePath[:idx]
+		}
+		if parent, ok := h.tree[parentPath]; ok {
+			t.parent = parent
+			parent.children = append(parent.children, t)
+		}
+	}
+
+	for _, t := range h.tree {
+		sort.Slice(t.children, func(i, j int) bool {
+			return t.children[i].name < t.children[j].name
+		})
+	}
+}
@@ -0,0 +1,197 @@
+package hierarchy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UsageStatePath returns the default usage state file, next to the hierarchy directory
+// It sits outside the directory so saving it doesn't trigger a hierarchy reload
+func UsageStatePath(hierarchyPath string) string {
+	return filepath.Clean(hierarchyPath) + ".usage.json"
+}
+
+// usageState is the on-disk format of the usage state file
+type usageState struct {
+	Counts  map[string]int `json:"counts"`
+	Updated time.Time      `json:"updated"`
+}
+
+// UsageStats counts tool calls per tool path and persists them to a state file
+type UsageStats struct {
+	path   string
+	counts map[string]int
+	dirty  bool
+	mu     sync.Mutex
+}
+
+// LoadUsageStats reads the usage state file, a missing file starts with no counts
+func LoadUsageStats(path string) (*UsageStats, error) {
+	u := &UsageStats{
+		path:   path,
+		counts: make(map[string]int),
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return u, nil
+	}
+	if err != nil {
+		return u, fmt.Errorf("failed to read usage state: %w", err)
+	}
+
+	var state usageState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return u, fmt.Errorf("failed to parse usage state %s: %w", path, err)
+	}
+	for toolPath, count := range state.Counts {
+		if count > 0 {
+			u.counts[toolPath] = count
+		}
+	}
+	return u, nil
+}
+
+// Record counts a call of the tool at toolPath
+func (u *UsageStats) Record(toolPath string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.counts[toolPath]++
+	u.dirty = true
+}
+
+// Count returns how often the tool at toolPath was called
+func (u *UsageStats) Count(toolPath string) int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.counts[toolPath]
+}
+
+// Ranked returns the called tool paths, most used first (ties by path)
+func (u *UsageStats) Ranked() []string {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	paths := make([]string, 0, len(u.counts))
+	for toolPath := range u.counts {
+		paths = append(paths, toolPath)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if u.counts[paths[i]] != u.counts[paths[j]] {
+			return u.counts[paths[i]] > u.counts[paths[j]]
+		}
+		return paths[i] < paths[j]
+	})
+	return paths
+}
+
+// Save writes the counts to the state file if they changed since the last save
+// The file is replaced atomically so a crash never leaves it half-written
+func (u *UsageStats) Save() error {
+	u.mu.Lock()
+	if !u.dirty {
+		u.mu.Unlock()
+		return nil
+	}
+	state := usageState{Counts: make(map[string]int, len(u.counts)), Updated: time.Now().UTC()}
+	for toolPath, count := range u.counts {
+		state.Counts[toolPath] = count
+	}
+	u.dirty = false
+	u.mu.Unlock()
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(u.path), "."+filepath.Base(u.path)+".*")
+	if err != nil {
+		u.markDirty()
+		return fmt.Errorf("failed to save usage state: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		u.markDirty()
+		return fmt.Errorf("failed to save usage state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		u.markDirty()
+		return fmt.Errorf("failed to save usage state: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), u.path); err != nil {
+		u.markDirty()
+		return fmt.Errorf("failed to save usage state: %w", err)
+	}
+	return nil
+}
+
+// markDirty makes the next Save retry after a failed write
+func (u *UsageStats) markDirty() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.dirty = true
+}
+
+// SetUsageStats makes HandleExecuteTool count successful calls in u
+func (h *Hierarchy) SetUsageStats(u *UsageStats) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.usage = u
+}
+
+// recordUsage counts a successful call if usage stats are enabled
+func (h *Hierarchy) recordUsage(toolPath string) {
+	h.mu.RLock()
+	usage := h.usage
+	h.mu.RUnlock()
+	if usage != nil {
+		usage.Record(toolPath)
+	}
+}
+
+// MostUsedTools picks the most called tools to promote to direct MCP tools
+// At most n tools are picked, and only while their definitions fit in maxTokens
+// (0 for no budget); tools in exclude, e.g. statically pinned ones, are skipped
+func (h *Hierarchy) MostUsedTools(usage *UsageStats, n, maxTokens int, exclude []string) []string {
+	if usage == nil || n <= 0 {
+		return nil
+	}
+
+	excluded := make(map[string]bool, len(exclude))
+	for _, toolPath := range exclude {
+		if toolDef, _, resolvedPath, err := h.resolveToolPath(toolPath); err == nil {
+			excluded[h.canonicalToolPath(toolDef, resolvedPath)] = true
+		}
+	}
+
+	var picked []string
+	used := 0
+	for _, toolPath := range usage.Ranked() {
+		if len(picked) >= n {
+			break
+		}
+		toolDef, _, resolvedPath, err := h.resolveToolPath(toolPath)
+		if err != nil {
+			continue // Removed from the hierarchy
+		}
+		resolvedPath = h.canonicalToolPath(toolDef, resolvedPath)
+		if excluded[resolvedPath] {
+			continue // Already pinned
+		}
+		cost := h.EstimateTokens(toolDef.mcpTool(resolvedPath[strings.LastIndex(resolvedPath, ".")+1:]))
+		if maxTokens > 0 && used+cost > maxTokens {
+			continue // A smaller, less used tool may still fit
+		}
+		used += cost
+		excluded[resolvedPath] = true
+		picked = append(picked, resolvedPath)
+	}
+	return picked
+}
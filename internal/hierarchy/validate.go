@@ -0,0 +1,220 @@
+package hierarchy
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// maxReportedViolations caps the violations returned to the model to keep errors compact
+const maxReportedViolations = 10
+
+// ArgumentValidationError is returned when execute_tool arguments don't match the tool's inputSchema
+type ArgumentValidationError struct {
+	ToolPath   string
+	Violations []string
+}
+
+func (e *ArgumentValidationError) Error() string {
+	violations := e.Violations
+	suffix := ""
+	if len(violations) > maxReportedViolations {
+		suffix = fmt.Sprintf("; and %d more", len(violations)-maxReportedViolations)
+		violations = violations[:maxReportedViolations]
+	}
+	return fmt.Sprintf("invalid arguments for %s: %s%s. Use describe_tool to see the expected schema.",
+		e.ToolPath, strings.Join(violations, "; "), suffix)
+}
+
+// ValidateArguments checks arguments against a JSON Schema object
+// Supports the subset used by MCP tool schemas: type, required, properties,
+// additionalProperties, enum and items. Unknown keywords are ignored.
+// Returns a list of human-readable violations, empty when arguments are valid.
+func ValidateArguments(schema map[string]interface{}, arguments map[string]interface{}) []string {
+	if schema == nil {
+		return nil
+	}
+	var violations []string
+	validateValue(schema, arguments, "", &violations)
+	return violations
+}
+
+// validateValue validates a single value against a schema, appending violations
+// path is the dotted location of the value, empty for the arguments object itself
+func validateValue(schema map[string]interface{}, value interface{}, path string, violations *[]string) {
+	if types := schemaTypes(schema); len(types) > 0 && !matchesAnyType(value, types) {
+		*violations = append(*violations, fmt.Sprintf("%s: expected %s, got %s",
+			displayPath(path), strings.Join(types, " or "), jsonTypeName(value)))
+		return // Nested checks are meaningless once the type is wrong
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok && len(enum) > 0 && !enumContains(enum, value) {
+		*violations = append(*violations, fmt.Sprintf("%s: must be one of %s", displayPath(path), formatEnum(enum)))
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		validateObject(schema, v, path, violations)
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				validateValue(items, item, fmt.Sprintf("%s[%d]", path, i), violations)
+			}
+		}
+	}
+}
+
+// validateObject checks required, properties and additionalProperties of an object value
+func validateObject(schema map[string]interface{}, object map[string]interface{}, path string, violations *[]string) {
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, r := range required {
+			name, ok := r.(string)
+			if !ok {
+				continue
+			}
+			if _, present := object[name]; !present {
+				*violations = append(*violations, fmt.Sprintf("%s: required property missing", joinPath(path, name)))
+			}
+		}
+	}
+
+	properties, _ := schema["properties"].(map[string]interface{})
+
+	// Iterate in sorted order so violations are reported deterministically
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if propSchema, ok := properties[name].(map[string]interface{}); ok {
+			validateValue(propSchema, object[name], joinPath(path, name), violations)
+			continue
+		}
+
+		// Only enforce additionalProperties where the schema declares it
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				*violations = append(*violations, fmt.Sprintf("%s: unknown property%s",
+					joinPath(path, name), knownPropertiesHint(properties)))
+			}
+		case map[string]interface{}:
+			validateValue(additional, object[name], joinPath(path, name), violations)
+		}
+	}
+}
+
+// schemaTypes returns the allowed types of a schema ("type" may be a string or a list)
+func schemaTypes(schema map[string]interface{}) []string {
+	switch t := schema["type"].(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		types := make([]string, 0, len(t))
+		for _, v := range t {
+			if s, ok := v.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+// matchesAnyType reports whether a decoded JSON value matches one of the schema types
+func matchesAnyType(value interface{}, types []string) bool {
+	actual := jsonTypeName(value)
+	for _, t := range types {
+		if t == actual {
+			return true
+		}
+		// Every integer is also a number
+		if t == "number" && actual == "integer" {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonTypeName returns the JSON Schema type name of a decoded JSON value
+func jsonTypeName(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) && !math.IsInf(v, 0) {
+			return "integer"
+		}
+		return "number"
+	case float32:
+		return jsonTypeName(float64(v))
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return "integer"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// enumContains reports whether value equals one of the enum entries
+func enumContains(enum []interface{}, value interface{}) bool {
+	for _, e := range enum {
+		if reflect.DeepEqual(e, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// formatEnum renders enum values for an error message
+func formatEnum(enum []interface{}) string {
+	values := make([]string, len(enum))
+	for i, e := range enum {
+		if s, ok := e.(string); ok {
+			values[i] = fmt.Sprintf("%q", s)
+		} else {
+			values[i] = fmt.Sprintf("%v", e)
+		}
+	}
+	return "[" + strings.Join(values, ", ") + "]"
+}
+
+// knownPropertiesHint lists the declared properties so the model can fix a typo in one step
+func knownPropertiesHint(properties map[string]interface{}) string {
+	if len(properties) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return " (expected one of: " + strings.Join(names, ", ") + ")"
+}
+
+// joinPath appends a property name to a dotted path
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// displayPath names the root arguments object when the path is empty
+func displayPath(path string) string {
+	if path == "" {
+		return "arguments"
+	}
+	return path
+}
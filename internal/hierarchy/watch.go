@@ -0,0 +1,77 @@
+package hierarchy
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Watch polls the hierarchy directory and reloads it whenever a JSON file is
+// added, removed or modified. onReload is called after every successful swap.
+// A reload that fails keeps the last good tree and logs the error.
+// Blocks until ctx is cancelled; run it in its own goroutine.
+func (h *Hierarchy) Watch(ctx context.Context, interval time.Duration, onReload func()) {
+	last, err := fingerprintHierarchy(h.rootPath)
+	if err != nil {
+		log.Printf("Warning: failed to fingerprint hierarchy at %s: %v", h.rootPath, err)
+	}
+
+	log.Printf("Watching hierarchy %s for changes (every %v)", h.rootPath, interval)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			current, err := fingerprintHierarchy(h.rootPath)
+			if err != nil {
+				log.Printf("Warning: failed to fingerprint hierarchy at %s: %v", h.rootPath, err)
+				continue
+			}
+			if current == last {
+				continue
+			}
+			// Remember the new state even if the reload fails, so a broken
+			// tree is reported once per change rather than on every tick
+			last = current
+
+			log.Printf("Hierarchy change detected in %s, reloading", h.rootPath)
+			if err := h.Reload(); err != nil {
+				log.Printf("Hierarchy reload failed, keeping last good tree: %v", err)
+				continue
+			}
+			if onReload != nil {
+				onReload()
+			}
+		}
+	}
+}
+
+// fingerprintHierarchy hashes the path, size and mtime of every JSON file in the tree
+// Any add, remove, rename or write changes the fingerprint
+func fingerprintHierarchy(hierarchyPath string) (uint64, error) {
+	hash := fnv.New64a()
+	err := filepath.Walk(hierarchyPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		// A bundle file is hashed whatever its extension; in a directory only JSON files count
+		if info.IsDir() || (path != hierarchyPath && !strings.HasSuffix(info.Name(), ".json")) {
+			return nil
+		}
+		_, err = fmt.Fprintf(hash, "%s\x00%d\x00%d\n", path, info.Size(), info.ModTime().UnixNano())
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return hash.Sum64(), nil
+}
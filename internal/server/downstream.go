@@ -0,0 +1,128 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/IAMSamuelRodda/mcp-proxy/internal/client"
+	"github.com/IAMSamuelRodda/mcp-proxy/internal/config"
+	"github.com/IAMSamuelRodda/mcp-proxy/internal/hierarchy"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// registerDownstreamCapabilities proxies prompts and resources of the downstream servers
+// Resources are exposed as mcp-proxy://<server>/<original-uri> and prompts as <server>__<name>
+func registerDownstreamCapabilities(mcpServer *server.MCPServer, registry *hierarchy.ServerRegistry) {
+	readResource := func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		return hierarchy.HandleReadResource(ctx, registry, request.Params.URI)
+	}
+
+	// Catch-all template, so any namespaced URI can be read even before its server is loaded
+	mcpServer.AddResourceTemplate(
+		mcp.NewResourceTemplate(
+			hierarchy.ResourceURIPrefix+"{server}/{+uri}",
+			"Downstream MCP server resource",
+			mcp.WithTemplateDescription("Read a resource from a downstream MCP server. The server is started on first access."),
+		),
+		readResource,
+	)
+
+	// A server's prompts and resources are listed once it is running and registered
+	// from the client's caches, whenever it is loaded
+	registry.OnServerLoaded(func(serverName string, c *client.Client) {
+		registerServerCapabilities(mcpServer, registry, serverName, c)
+	})
+}
+
+// registerServerCapabilities lists the prompts and resources of a running server and
+// registers them under namespaced names
+func registerServerCapabilities(mcpServer *server.MCPServer, registry *hierarchy.ServerRegistry, serverName string, c *client.Client) {
+	readResource := func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		return hierarchy.HandleReadResource(ctx, registry, request.Params.URI)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	c.LoadPromptsAndResources(ctx)
+
+	for _, prompt := range c.LazyPrompts() {
+		originalName := prompt.Name
+		prompt.Name = hierarchy.NamespacePromptName(serverName, originalName)
+		mcpServer.AddPrompt(prompt, func(ctx context.Context, request mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+			return hierarchy.HandleGetPrompt(ctx, registry, serverName, originalName, request.Params.Arguments)
+		})
+	}
+
+	for _, resource := range c.LazyResources() {
+		resource.URI = hierarchy.NamespaceResourceURI(serverName, resource.URI)
+		mcpServer.AddResource(resource, readResource)
+	}
+
+	templates := 0
+	for _, template := range c.LazyResourceTemplates() {
+		if template.URITemplate == nil {
+			continue
+		}
+		namespaced, err := namespaceURITemplate(serverName, template.URITemplate.Raw())
+		if err != nil {
+			log.Printf("<%s> Skipping resource template %s: %v", serverName, template.Name, err)
+			continue
+		}
+		template.URITemplate = namespaced
+		mcpServer.AddResourceTemplate(template, readResource)
+		templates++
+	}
+	log.Printf("<%s> Proxying %d prompts, %d resources and %d resource templates",
+		serverName, len(c.LazyPrompts()), len(c.LazyResources()), templates)
+}
+
+// discoverDownstreamCapabilities starts the servers that aren't running yet, one at a time,
+// so their prompts and resources are listed at startup rather than after some tool call
+// loads them. These starts skip the load callback: each server is registered here, then
+// closed again and starts on first use
+func discoverDownstreamCapabilities(ctx context.Context, mcpServer *server.MCPServer, registry *hierarchy.ServerRegistry) {
+	names := registry.GetServerNames()
+	sort.Strings(names)
+	for _, serverName := range names {
+		if ctx.Err() != nil {
+			return
+		}
+		c, err := registry.StartServerQuietly(ctx, serverName)
+		if err != nil {
+			log.Printf("<%s> Could not list prompts and resources: %v", serverName, err)
+			continue
+		}
+		if c == nil {
+			continue // Already running or starting, registered by the load callback
+		}
+		registerServerCapabilities(mcpServer, registry, serverName, c)
+		registry.CloseServer(serverName, c)
+	}
+}
+
+// startDownstreamDiscovery lists downstream prompts and resources in the background at
+// startup when discoverDownstream is on, unless preloadAll already starts every server
+func startDownstreamDiscovery(ctx context.Context, cfg *config.Config, mcpServer *server.MCPServer, registry *hierarchy.ServerRegistry) {
+	options := cfg.McpProxy.Options
+	if options == nil || options.PreloadAll.OrElse(false) || !options.DiscoverDownstream.OrElse(false) {
+		return
+	}
+	go discoverDownstreamCapabilities(ctx, mcpServer, registry)
+}
+
+// namespaceURITemplate prefixes a downstream URI template with the proxy namespace
+func namespaceURITemplate(serverName, raw string) (*mcp.URITemplate, error) {
+	quoted, err := json.Marshal(hierarchy.NamespaceResourceURI(serverName, raw))
+	if err != nil {
+		return nil, err
+	}
+	template := &mcp.URITemplate{}
+	if err := template.UnmarshalJSON(quoted); err != nil {
+		return nil, err
+	}
+	return template, nil
+}
@@ -0,0 +1,202 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/IAMSamuelRodda/mcp-proxy/internal/config"
+	"github.com/IAMSamuelRodda/mcp-proxy/internal/hierarchy"
+)
+
+// elicitationRequestPrefix marks the JSON-RPC ids of elicitation requests sent by the proxy
+// String ids keep them apart from mcp-go's own numeric sampling request ids
+const elicitationRequestPrefix = "mcp-proxy-elicitation-"
+
+// elicitationBridge lets the stdio server send elicitation/create requests
+// mcp-go v0.39.1 doesn't implement elicitation, so the bridge sits between the stdio
+// transport and the real stdin/stdout: it writes requests itself, takes their
+// responses out of the input stream before mcp-go sees them, and watches the
+// initialize request for the client's elicitation capability
+type elicitationBridge struct {
+	in        *bufio.Reader
+	out       io.Writer
+	buffered  []byte // Rest of the line being passed to mcp-go
+	writeMu   sync.Mutex
+	supported atomic.Bool
+	nextID    atomic.Int64
+	pending   map[string]chan elicitationResponse
+	pendingMu sync.Mutex
+}
+
+// elicitationResponse is the client's answer to an elicitation request
+type elicitationResponse struct {
+	Result *struct {
+		Action  string                 `json:"action"` // "accept", "decline" or "cancel"
+		Content map[string]interface{} `json:"content,omitempty"`
+	} `json:"result,omitempty"`
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+func newElicitationBridge(in io.Reader, out io.Writer) *elicitationBridge {
+	return &elicitationBridge{
+		in:      bufio.NewReader(in),
+		out:     out,
+		pending: make(map[string]chan elicitationResponse),
+	}
+}
+
+// Read passes client messages to mcp-go, except responses to elicitation requests
+func (b *elicitationBridge) Read(p []byte) (int, error) {
+	for len(b.buffered) == 0 {
+		line, err := b.in.ReadBytes('\n')
+		if len(line) > 0 && !b.intercept(line) {
+			b.buffered = line
+		}
+		if err != nil {
+			if len(b.buffered) == 0 {
+				return 0, err
+			}
+			break
+		}
+	}
+	n := copy(p, b.buffered)
+	b.buffered = b.buffered[n:]
+	return n, nil
+}
+
+// Write sends a server message to the client, one whole message at a time
+func (b *elicitationBridge) Write(p []byte) (int, error) {
+	b.writeMu.Lock()
+	defer b.writeMu.Unlock()
+	return b.out.Write(p)
+}
+
+// intercept handles a line from the client, returning true if it was consumed
+func (b *elicitationBridge) intercept(line []byte) bool {
+	var message struct {
+		ID     json.RawMessage `json:"id,omitempty"`
+		Method string          `json:"method,omitempty"`
+		Params struct {
+			Capabilities map[string]json.RawMessage `json:"capabilities,omitempty"`
+		} `json:"params,omitempty"`
+	}
+	if err := json.Unmarshal(line, &message); err != nil {
+		return false
+	}
+
+	if message.Method == "initialize" {
+		_, ok := message.Params.Capabilities["elicitation"]
+		b.supported.Store(ok)
+		return false
+	}
+
+	var id string
+	if message.Method != "" || json.Unmarshal(message.ID, &id) != nil || !strings.HasPrefix(id, elicitationRequestPrefix) {
+		return false
+	}
+
+	var response elicitationResponse
+	if err := json.Unmarshal(line, &response); err != nil {
+		response.Error = &struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		}{Message: fmt.Sprintf("invalid elicitation response: %v", err)}
+	}
+
+	b.pendingMu.Lock()
+	responseChan, ok := b.pending[id]
+	delete(b.pending, id)
+	b.pendingMu.Unlock()
+	if ok {
+		responseChan <- response
+	}
+	return true
+}
+
+// Confirm asks the user to approve a tool call with an elicitation/create request
+func (b *elicitationBridge) Confirm(ctx context.Context, request hierarchy.ConfirmationRequest) (bool, error) {
+	if !b.supported.Load() {
+		return false, hierarchy.ErrConfirmationUnsupported
+	}
+
+	id := fmt.Sprintf("%s%d", elicitationRequestPrefix, b.nextID.Add(1))
+	responseChan := make(chan elicitationResponse, 1)
+	b.pendingMu.Lock()
+	b.pending[id] = responseChan
+	b.pendingMu.Unlock()
+	defer func() {
+		b.pendingMu.Lock()
+		delete(b.pending, id)
+		b.pendingMu.Unlock()
+	}()
+
+	message, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      id,
+		"method":  "elicitation/create",
+		"params": map[string]interface{}{
+			"message": request.Message(),
+			"requestedSchema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"approve": map[string]interface{}{
+						"type":        "boolean",
+						"title":       "Approve",
+						"description": fmt.Sprintf("Run %s with these arguments", request.ToolPath),
+					},
+				},
+				"required": []string{"approve"},
+			},
+		},
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal elicitation request: %w", err)
+	}
+	if _, err := b.Write(append(message, '\n')); err != nil {
+		return false, fmt.Errorf("failed to send elicitation request: %w", err)
+	}
+
+	select {
+	case response := <-responseChan:
+		if response.Error != nil {
+			return false, fmt.Errorf("elicitation request failed: %s", response.Error.Message)
+		}
+		if response.Result == nil || response.Result.Action != "accept" {
+			return false, nil
+		}
+		// Only an explicit approve: true counts, a missing or malformed field denies
+		approve, ok := response.Result.Content["approve"].(bool)
+		return ok && approve, nil
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+}
+
+// newConfirmationPolicy builds the hierarchy confirmation policy from the confirmTools option
+// Returns nil when no tools need confirmation
+func newConfirmationPolicy(options *config.OptionsV2, confirmer hierarchy.Confirmer) *hierarchy.ConfirmationPolicy {
+	if options == nil || options.ConfirmTools == nil {
+		return nil
+	}
+	confirmTools := options.ConfirmTools
+	if !confirmTools.Enabled() {
+		return nil
+	}
+	return &hierarchy.ConfirmationPolicy{
+		Destructive:          confirmTools.Destructive,
+		Patterns:             confirmTools.Patterns,
+		AllowWhenUnsupported: config.ConfirmFallback(strings.ToLower(string(confirmTools.Fallback))) == config.ConfirmFallbackAllow,
+		Timeout:              time.Duration(confirmTools.TimeoutMs.OrElse(hierarchy.DefaultConfirmTimeoutMs)) * time.Millisecond,
+		Confirmer:            confirmer,
+	}
+}
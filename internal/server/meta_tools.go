@@ -0,0 +1,339 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/IAMSamuelRodda/mcp-proxy/internal/config"
+	"github.com/IAMSamuelRodda/mcp-proxy/internal/hierarchy"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// registerMetaTools registers the hierarchy meta-tools on the MCP server
+// Shared by the stdio and HTTP servers so both expose the same tool surface
+func registerMetaTools(mcpServer *server.MCPServer, cfg *config.Config, h *hierarchy.Hierarchy, registry *hierarchy.ServerRegistry) *metaToolSet {
+	configureTokenAccounting(cfg.McpProxy.Options, h)
+	tools := &metaToolSet{mcpServer: mcpServer, h: h}
+
+	// Register get_tools_in_category meta-tool
+	registerGetToolsInCategory(tools, h)
+
+	// Register execute_tool meta-tool
+	executeToolTool := mcp.Tool{
+		Name:        "execute_tool",
+		Description: "Execute a tool by its full path. Automatically proxies the request to the appropriate MCP server.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"tool_path": map[string]interface{}{
+					"type":        "string",
+					"description": "Full tool path using dot notation (e.g., 'coding_tools.serena.search.search_symbol') or just tool name if unique",
+				},
+				"arguments": map[string]interface{}{
+					"type":                 "object",
+					"description":          "Arguments to pass to the tool",
+					"additionalProperties": true,
+				},
+			},
+			Required: []string{"tool_path", "arguments"},
+		},
+	}
+
+	tools.addMetaTool(executeToolTool, countToolOutput(h, executeToolTool.Name, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		toolPath := ""
+		arguments := make(map[string]interface{})
+
+		if request.Params.Arguments != nil {
+			if argsMap, ok := request.Params.Arguments.(map[string]interface{}); ok {
+				if pathVal, ok := argsMap["tool_path"].(string); ok {
+					toolPath = pathVal
+				}
+				if argsVal, ok := argsMap["arguments"].(map[string]interface{}); ok {
+					arguments = argsVal
+				}
+			}
+		}
+
+		if toolPath == "" {
+			return nil, fmt.Errorf("tool_path is required")
+		}
+
+		return h.HandleExecuteTool(ctx, registry, toolPath, arguments)
+	}))
+
+	// Register execute_tools meta-tool
+	batchConcurrency := hierarchy.DefaultBatchConcurrency
+	if cfg.McpProxy.Options != nil && cfg.McpProxy.Options.BatchConcurrency.OrElse(0) > 0 {
+		batchConcurrency = cfg.McpProxy.Options.BatchConcurrency.OrElse(hierarchy.DefaultBatchConcurrency)
+	}
+
+	executeToolsTool := mcp.Tool{
+		Name:        "execute_tools",
+		Description: fmt.Sprintf("Execute several independent tools in parallel (up to %d per batch). Results are returned in the same order as the calls, each with its own success flag and result or error, so one failing call doesn't affect the others. Use this instead of repeated execute_tool calls when the calls don't depend on each other.", hierarchy.MaxBatchSize),
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"calls": map[string]interface{}{
+					"type":        "array",
+					"description": "Tool calls to run, each with the same tool_path and arguments as execute_tool",
+					"items": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"tool_path": map[string]interface{}{
+								"type":        "string",
+								"description": "Full tool path using dot notation, or just tool name if unique",
+							},
+							"arguments": map[string]interface{}{
+								"type":                 "object",
+								"description":          "Arguments to pass to the tool",
+								"additionalProperties": true,
+							},
+						},
+						"required": []string{"tool_path"},
+					},
+				},
+			},
+			Required: []string{"calls"},
+		},
+	}
+
+	tools.addMetaTool(executeToolsTool, countToolOutput(h, executeToolsTool.Name, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		var calls []hierarchy.BatchCall
+
+		if request.Params.Arguments != nil {
+			if argsMap, ok := request.Params.Arguments.(map[string]interface{}); ok {
+				if callsVal, ok := argsMap["calls"].([]interface{}); ok {
+					for _, entry := range callsVal {
+						call := hierarchy.BatchCall{}
+						if callMap, ok := entry.(map[string]interface{}); ok {
+							if pathVal, ok := callMap["tool_path"].(string); ok {
+								call.ToolPath = pathVal
+							}
+							if argsVal, ok := callMap["arguments"].(map[string]interface{}); ok {
+								call.Arguments = argsVal
+							}
+						}
+						calls = append(calls, call)
+					}
+				}
+			}
+		}
+
+		response, err := h.HandleExecuteTools(ctx, registry, calls, batchConcurrency)
+		if err != nil {
+			return nil, err
+		}
+
+		return newJSONResult(response)
+	}))
+
+	// Register fetch_result_page meta-tool
+	if cfg.McpProxy.Options != nil && cfg.McpProxy.Options.ResultTTLMs.OrElse(0) > 0 {
+		h.Results().SetTTL(time.Duration(cfg.McpProxy.Options.ResultTTLMs.OrElse(0)) * time.Millisecond)
+	}
+
+	fetchResultPageTool := mcp.Tool{
+		Name:        "fetch_result_page",
+		Description: "Fetch the next page of a tool result that was too large to return at once. Oversized results end with a note giving the handle and the offset of the next page.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"handle": map[string]interface{}{
+					"type":        "string",
+					"description": "Result handle from the truncation note (e.g., 'res_1a2b3c4d5e6f7a8b')",
+				},
+				"offset": map[string]interface{}{
+					"type":        "integer",
+					"description": "Byte offset to continue from, as given in the truncation note (default 0)",
+				},
+			},
+			Required: []string{"handle"},
+		},
+	}
+
+	tools.addMetaTool(fetchResultPageTool, countToolOutput(h, fetchResultPageTool.Name, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		handle := ""
+		offset := 0
+		if request.Params.Arguments != nil {
+			if argsMap, ok := request.Params.Arguments.(map[string]interface{}); ok {
+				if handleVal, ok := argsMap["handle"].(string); ok {
+					handle = handleVal
+				}
+				if offsetVal, ok := argsMap["offset"].(float64); ok {
+					offset = int(offsetVal)
+				}
+			}
+		}
+
+		if handle == "" {
+			return nil, fmt.Errorf("handle is required")
+		}
+
+		return h.Results().HandleFetchResultPage(handle, offset)
+	}))
+
+	// Register search_tools meta-tool
+	searchToolsTool := mcp.Tool{
+		Name:        "search_tools",
+		Description: "Search all tools in every category by keyword. Matches tool names, descriptions, category overviews and argument names, and returns the best matches with their tool_path for execute_tool. Use this instead of browsing categories when you know roughly what you need.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"query": map[string]interface{}{
+					"type":        "string",
+					"description": "Free-text keywords describing the tool (e.g., 'create github issue' or 'read file')",
+				},
+				"limit": map[string]interface{}{
+					"type":        "integer",
+					"description": fmt.Sprintf("Maximum number of results to return (default %d, max %d)", hierarchy.DefaultSearchLimit, hierarchy.MaxSearchLimit),
+				},
+			},
+			Required: []string{"query"},
+		},
+	}
+
+	tools.addMetaTool(searchToolsTool, countResponseTokens(h, searchToolsTool.Name, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		query := ""
+		limit := 0
+
+		if request.Params.Arguments != nil {
+			if argsMap, ok := request.Params.Arguments.(map[string]interface{}); ok {
+				if queryVal, ok := argsMap["query"].(string); ok {
+					query = queryVal
+				}
+				if limitVal, ok := argsMap["limit"].(float64); ok {
+					limit = int(limitVal)
+				}
+			}
+		}
+
+		response, err := h.HandleSearchTools(query, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		return newJSONResult(response)
+	}))
+
+	// Register describe_tool meta-tool
+	describeToolTool := mcp.Tool{
+		Name:        "describe_tool",
+		Description: "Get the full definition of a tool: title, description, inputSchema, outputSchema, annotations and server. Call this before execute_tool to learn the exact argument names and types.",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"tool_path": map[string]interface{}{
+					"type":        "string",
+					"description": "Tool path as accepted by execute_tool (e.g., 'coding_tools.serena.search.search_symbol')",
+				},
+			},
+			Required: []string{"tool_path"},
+		},
+	}
+
+	tools.addMetaTool(describeToolTool, countResponseTokens(h, describeToolTool.Name, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		toolPath := ""
+		if request.Params.Arguments != nil {
+			if argsMap, ok := request.Params.Arguments.(map[string]interface{}); ok {
+				if pathVal, ok := argsMap["tool_path"].(string); ok {
+					toolPath = pathVal
+				}
+			}
+		}
+
+		if toolPath == "" {
+			return nil, fmt.Errorf("tool_path is required")
+		}
+
+		response, err := h.HandleDescribeTool(toolPath)
+		if err != nil {
+			return nil, err
+		}
+
+		return newJSONResult(response)
+	}))
+
+	return tools
+}
+
+// registerGetToolsInCategory registers (or re-registers) the get_tools_in_category meta-tool
+// Its description embeds the root overview, so it is re-registered after a hierarchy
+// reload; re-adding a tool makes the server send notifications/tools/list_changed
+func registerGetToolsInCategory(tools *metaToolSet, h *hierarchy.Hierarchy) {
+	// Build description from root overview
+	description := "You have MCP tools hidden within categories. You MUST use get_tools_in_category to learn more about what available tools you have within these categories. Returns children categories, and tools at the specified path. Call initially with an empty string to get root categories."
+
+	// Get root node and use its overview
+	if rootNode := h.GetRootNode(); rootNode != nil && rootNode.Overview != "" {
+		description += fmt.Sprintf("\n\n%s", rootNode.Overview)
+	}
+
+	getToolsInCategoryTool := mcp.Tool{
+		Name:        "get_tools_in_category",
+		Description: description,
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"path": map[string]interface{}{
+					"type":        "string",
+					"description": "Category path using dot notation (e.g., 'coding_tools' or 'coding_tools.serena.search'). Use empty string or '/' for root.",
+				},
+				"depth": map[string]interface{}{
+					"type":        "integer",
+					"description": fmt.Sprintf("Number of levels to expand (default 1, max %d). Use 2-3 to see a small subtree in one call.", hierarchy.MaxCategoryDepth),
+				},
+				"max_tokens": map[string]interface{}{
+					"type":        "integer",
+					"description": "Optional token budget for the response. Subcategories that would exceed it are marked truncated; list them separately. If even one level doesn't fit, entries are cut and counted under more_available.",
+				},
+			},
+			Required: []string{"path"},
+		},
+	}
+
+	log.Printf("Tokens: get_tools_in_category description ~%d", h.EstimateTokens(description))
+
+	tools.addMetaTool(getToolsInCategoryTool, countResponseTokens(h, getToolsInCategoryTool.Name, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		path := ""
+		depth := 1
+		maxTokens := 0
+		if request.Params.Arguments != nil {
+			if argsMap, ok := request.Params.Arguments.(map[string]interface{}); ok {
+				if pathVal, ok := argsMap["path"].(string); ok {
+					path = pathVal
+				}
+				if depthVal, ok := argsMap["depth"].(float64); ok {
+					depth = int(depthVal)
+				}
+				if maxTokensVal, ok := argsMap["max_tokens"].(float64); ok {
+					maxTokens = int(maxTokensVal)
+				}
+			}
+		}
+
+		response, err := h.HandleGetToolsInCategory(path, depth, maxTokens)
+		if err != nil {
+			return nil, err
+		}
+
+		return newJSONResult(response)
+	}))
+}
+
+// newJSONResult marshals a meta-tool response into an indented JSON text result
+func newJSONResult(response interface{}) (*mcp.CallToolResult, error) {
+	jsonBytes, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.NewTextContent(string(jsonBytes)),
+		},
+	}, nil
+}
@@ -0,0 +1,203 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/IAMSamuelRodda/mcp-proxy/internal/config"
+	"github.com/IAMSamuelRodda/mcp-proxy/internal/hierarchy"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Defaults for usage-driven adaptive pinning
+const (
+	defaultAdaptivePinMaxTokens = 2000
+	defaultUsageSaveInterval    = time.Minute
+)
+
+// pinnedToolSet keeps the pinned tools registered on the MCP server in sync with the
+// hierarchy: the static pinnedTools plus, with adaptive pinning, the most used tools
+type pinnedToolSet struct {
+	mcpServer         *server.MCPServer
+	h                 *hierarchy.Hierarchy
+	registry          *hierarchy.ServerRegistry
+	metaTools         *metaToolSet // Pinned tools never take the names of meta-tools
+	static            []string
+	usage             *hierarchy.UsageStats // nil when adaptive pinning is disabled
+	adaptiveCount     int
+	adaptiveMaxTokens int
+	registered        map[string]hierarchy.PinnedTool // MCP tool name -> pinned tool
+	mu                sync.Mutex
+}
+
+func newPinnedToolSet(mcpServer *server.MCPServer, h *hierarchy.Hierarchy, registry *hierarchy.ServerRegistry, metaTools *metaToolSet, static []string) *pinnedToolSet {
+	return &pinnedToolSet{
+		mcpServer:  mcpServer,
+		h:          h,
+		registry:   registry,
+		metaTools:  metaTools,
+		static:     static,
+		registered: make(map[string]hierarchy.PinnedTool),
+	}
+}
+
+// sync registers the pinned tools and removes ones that are gone, e.g. after a reload
+// Nothing is sent to the client when the tool definitions didn't change
+func (p *pinnedToolSet) sync() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	toolPaths := p.static
+	adaptive := p.h.MostUsedTools(p.usage, p.adaptiveCount, p.adaptiveMaxTokens, p.static)
+	if len(adaptive) > 0 {
+		toolPaths = append(append([]string{}, p.static...), adaptive...)
+	}
+
+	pinned, errs := p.h.PinnedTools(toolPaths, p.metaTools.names)
+	for _, err := range errs {
+		log.Printf("Warning: %v", err)
+	}
+
+	current := make(map[string]hierarchy.PinnedTool, len(pinned))
+	for _, pt := range pinned {
+		current[pt.Name] = pt
+	}
+	if samePinnedTools(p.registered, current) {
+		return
+	}
+
+	var stale []string
+	for name := range p.registered {
+		if _, ok := current[name]; !ok {
+			stale = append(stale, name)
+		}
+	}
+	if len(stale) > 0 {
+		p.mcpServer.DeleteTools(stale...)
+		p.h.Tokens().RemoveDefinitions(stale...)
+	}
+
+	serverTools := make([]server.ServerTool, 0, len(pinned))
+	for _, pt := range pinned {
+		toolPath := pt.ToolPath
+		serverTools = append(serverTools, server.ServerTool{
+			Tool: pt.Tool,
+			Handler: countToolOutput(p.h, pt.Name, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+				arguments, _ := request.Params.Arguments.(map[string]interface{})
+				if arguments == nil {
+					arguments = make(map[string]interface{})
+				}
+				return p.h.HandleExecuteTool(ctx, p.registry, toolPath, arguments)
+			}),
+		})
+		p.h.Tokens().SetDefinition(pt.Name, p.h.EstimateTokens(pt.Tool))
+		if !strings.HasSuffix("."+toolPath, "."+pt.Name) {
+			log.Printf("Pinned tool %s as %s to avoid a name collision", toolPath, pt.Name)
+		}
+	}
+	if len(serverTools) > 0 {
+		p.mcpServer.AddTools(serverTools...)
+	}
+
+	p.registered = current
+	if len(adaptive) > 0 {
+		log.Printf("Registered %d pinned tools, promoted by usage: %s", len(current), strings.Join(adaptive, ", "))
+	} else {
+		log.Printf("Registered %d pinned tools", len(current))
+	}
+	log.Printf("Tokens: %s", p.h.TokenSummary())
+}
+
+// watchUsage saves the usage counts periodically and re-ranks the promoted tools
+// The client gets notifications/tools/list_changed only when the ranking changes them
+func (p *pinnedToolSet) watchUsage(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.saveUsage()
+			return
+		case <-ticker.C:
+			p.saveUsage()
+			p.sync()
+		}
+	}
+}
+
+// saveUsage writes the usage counts to the state file
+func (p *pinnedToolSet) saveUsage() {
+	if p.usage == nil {
+		return
+	}
+	if err := p.usage.Save(); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+// samePinnedTools compares two sets of pinned tools by name, path and definition
+func samePinnedTools(a, b map[string]hierarchy.PinnedTool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, pa := range a {
+		pb, ok := b[name]
+		if !ok || pa.ToolPath != pb.ToolPath {
+			return false
+		}
+		ja, errA := json.Marshal(pa.Tool)
+		jb, errB := json.Marshal(pb.Tool)
+		if errA != nil || errB != nil || !bytes.Equal(ja, jb) {
+			return false
+		}
+	}
+	return true
+}
+
+// registerPinnedTools registers the pinnedTools option as first-class MCP tools
+// With adaptivePinCount set, call counts are loaded from the usage state file, the
+// most used tools are promoted too, and the ranking is refreshed until ctx is done
+func registerPinnedTools(ctx context.Context, mcpServer *server.MCPServer, cfg *config.Config, h *hierarchy.Hierarchy, registry *hierarchy.ServerRegistry, metaTools *metaToolSet) *pinnedToolSet {
+	opts := cfg.McpProxy.Options
+	if opts == nil {
+		return newPinnedToolSet(mcpServer, h, registry, metaTools, nil)
+	}
+	pinned := newPinnedToolSet(mcpServer, h, registry, metaTools, opts.PinnedTools)
+
+	if opts.AdaptivePinCount.OrElse(0) > 0 {
+		statePath := opts.UsageStatePath
+		if statePath == "" {
+			statePath = hierarchy.UsageStatePath(cfg.McpProxy.HierarchyPath)
+		}
+		usage, err := hierarchy.LoadUsageStats(statePath)
+		if err != nil {
+			log.Printf("Warning: %v, starting with empty usage counts", err)
+		}
+		h.SetUsageStats(usage)
+
+		pinned.usage = usage
+		pinned.adaptiveCount = opts.AdaptivePinCount.OrElse(0)
+		pinned.adaptiveMaxTokens = defaultAdaptivePinMaxTokens
+		if opts.AdaptivePinMaxTokens.Present() {
+			pinned.adaptiveMaxTokens = opts.AdaptivePinMaxTokens.OrElse(defaultAdaptivePinMaxTokens)
+		}
+
+		interval := defaultUsageSaveInterval
+		if opts.UsageSaveIntervalMs.OrElse(0) > 0 {
+			interval = time.Duration(opts.UsageSaveIntervalMs.OrElse(0)) * time.Millisecond
+		}
+		go pinned.watchUsage(ctx, interval)
+	}
+
+	if len(pinned.static) > 0 || pinned.usage != nil {
+		pinned.sync()
+	}
+	return pinned
+}
@@ -2,7 +2,6 @@ package server
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
@@ -17,7 +16,6 @@ import (
 	"github.com/IAMSamuelRodda/mcp-proxy/internal/hierarchy"
 	"github.com/IAMSamuelRodda/mcp-proxy/internal/secrets"
 	"github.com/IAMSamuelRodda/mcp-proxy/internal/secrets/openbao"
-	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 )
 
@@ -77,6 +75,28 @@ func recoverMiddleware(prefix string) MiddlewareFunc {
 	}
 }
 
+// startHierarchyWatcher polls the hierarchy directory in the background and swaps in
+// the new tree on change, refreshing the root overview in get_tools_in_category
+// and the servers declared in the hierarchy, and re-registering pinned tools
+func startHierarchyWatcher(ctx context.Context, cfg *config.Config, h *hierarchy.Hierarchy, registry *hierarchy.ServerRegistry, metaTools *metaToolSet, pinned *pinnedToolSet) {
+	opts := cfg.McpProxy.Options
+	if opts != nil && !opts.WatchHierarchy.OrElse(true) {
+		return
+	}
+
+	intervalMs := 2000
+	if opts != nil && opts.HierarchyPollIntervalMs.OrElse(0) > 0 {
+		intervalMs = opts.HierarchyPollIntervalMs.OrElse(2000)
+	}
+
+	go h.Watch(ctx, time.Duration(intervalMs)*time.Millisecond, func() {
+		registerGetToolsInCategory(metaTools, h)
+		serverConfigs, _ := h.ServerConfigs(cfg.McpProxy.Options, cfg.ExpandEnv)
+		registry.MergeServerConfigs(serverConfigs)
+		pinned.sync()
+	})
+}
+
 // StartStdioServer starts the stdio server with the given configuration
 func StartStdioServer(cfg *config.Config) error {
 	// Check secrets provider availability (graceful - warns but doesn't block)
@@ -123,15 +143,15 @@ func StartStdioServer(cfg *config.Config) error {
 	registry := hierarchy.NewServerRegistry(cfg.McpServers)
 	defer registry.Close()
 
-	// Background preload: start all servers immediately if preloadAll is enabled
-	// This eliminates first-call latency while keeping context window savings
-	if cfg.McpProxy.Options != nil && cfg.McpProxy.Options.PreloadAll.OrElse(false) {
-		go registry.PreloadServers(context.Background())
-	}
+	// Add servers declared in hierarchy mcp_server blocks (conflicts are logged)
+	serverConfigs, _ := h.ServerConfigs(cfg.McpProxy.Options, cfg.ExpandEnv)
+	registry.MergeServerConfigs(serverConfigs)
 
-	// Create ONE MCP server with 2 meta-tools
+	// Create ONE MCP server exposing the hierarchy meta-tools
 	serverOpts := []server.ServerOption{
 		server.WithResourceCapabilities(true, true),
+		server.WithToolCapabilities(true),
+		server.WithPromptCapabilities(true),
 		server.WithRecovery(),
 	}
 
@@ -145,103 +165,43 @@ func StartStdioServer(cfg *config.Config) error {
 		serverOpts...,
 	)
 
-	// Register get_tools_in_category meta-tool
-	// Build description from root overview
-	description := "You have MCP tools hidden within categories. You MUST use get_tools_in_category to learn more about what available tools you have within these categories. Returns children categories, and tools at the specified path. Call initially with an empty string to get root categories."
-
-	// Get root node and use its overview
-	if rootNode := h.GetRootNode(); rootNode != nil && rootNode.Overview != "" {
-		description += fmt.Sprintf("\n\n%s", rootNode.Overview)
-	}
-
-	getToolsInCategoryTool := mcp.Tool{
-		Name:        "get_tools_in_category",
-		Description: description,
-		InputSchema: mcp.ToolInputSchema{
-			Type: "object",
-			Properties: map[string]interface{}{
-				"path": map[string]interface{}{
-					"type":        "string",
-					"description": "Category path using dot notation (e.g., 'coding_tools' or 'coding_tools.serena.search'). Use empty string or '/' for root.",
-				},
-			},
-			Required: []string{"path"},
-		},
-	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 
-	mcpServer.AddTool(getToolsInCategoryTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		path := ""
-		if request.Params.Arguments != nil {
-			if argsMap, ok := request.Params.Arguments.(map[string]interface{}); ok {
-				if pathVal, ok := argsMap["path"].(string); ok {
-					path = pathVal
-				}
-			}
-		}
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGTERM, syscall.SIGINT)
+	go func() {
+		<-sigChan
+		cancel()
+	}()
 
-		response, err := h.HandleGetToolsInCategory(path)
-		if err != nil {
-			return nil, err
-		}
+	// Register the meta-tools (get_tools_in_category, execute_tool, execute_tools, fetch_result_page, search_tools, describe_tool)
+	metaTools := registerMetaTools(mcpServer, cfg, h, registry)
 
-		jsonBytes, err := json.MarshalIndent(response, "", "  ")
-		if err != nil {
-			return nil, err
-		}
+	// Register pinnedTools directly, next to the meta-tools (hybrid mode), plus the most used tools
+	pinned := registerPinnedTools(ctx, mcpServer, cfg, h, registry, metaTools)
+	defer pinned.saveUsage()
+	log.Printf("Tokens: %s", h.TokenSummary())
 
-		return &mcp.CallToolResult{
-			Content: []mcp.Content{
-				mcp.NewTextContent(string(jsonBytes)),
-			},
-		}, nil
-	})
+	// Proxy downstream prompts and resources under namespaced names
+	registerDownstreamCapabilities(mcpServer, registry)
 
-	// Register execute_tool meta-tool
-	executeToolTool := mcp.Tool{
-		Name:        "execute_tool",
-		Description: "Execute a tool by its full path. Automatically proxies the request to the appropriate MCP server.",
-		InputSchema: mcp.ToolInputSchema{
-			Type: "object",
-			Properties: map[string]interface{}{
-				"tool_path": map[string]interface{}{
-					"type":        "string",
-					"description": "Full tool path using dot notation (e.g., 'coding_tools.serena.search.search_symbol') or just tool name if unique",
-				},
-				"arguments": map[string]interface{}{
-					"type":                 "object",
-					"description":          "Arguments to pass to the tool",
-					"additionalProperties": true,
-				},
-			},
-			Required: []string{"tool_path", "arguments"},
-		},
+	// Background preload: start all servers immediately if preloadAll is enabled
+	// This eliminates first-call latency while keeping context window savings
+	if cfg.McpProxy.Options != nil && cfg.McpProxy.Options.PreloadAll.OrElse(false) {
+		go registry.PreloadServers(context.Background())
 	}
+	startDownstreamDiscovery(ctx, cfg, mcpServer, registry)
 
-	mcpServer.AddTool(executeToolTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		toolPath := ""
-		arguments := make(map[string]interface{})
+	// Hot reload the hierarchy when files under hierarchyPath change
+	startHierarchyWatcher(ctx, cfg, h, registry, metaTools, pinned)
 
-		if request.Params.Arguments != nil {
-			if argsMap, ok := request.Params.Arguments.(map[string]interface{}); ok {
-				if pathVal, ok := argsMap["tool_path"].(string); ok {
-					toolPath = pathVal
-				}
-				if argsVal, ok := argsMap["arguments"].(map[string]interface{}); ok {
-					arguments = argsVal
-				}
-			}
-		}
-
-		if toolPath == "" {
-			return nil, fmt.Errorf("tool_path is required")
-		}
-
-		return h.HandleExecuteTool(ctx, registry, toolPath, arguments)
-	})
+	// Serve via stdio through the elicitation bridge, so destructive tools can be confirmed
+	bridge := newElicitationBridge(os.Stdin, os.Stdout)
+	h.SetConfirmationPolicy(newConfirmationPolicy(cfg.McpProxy.Options, bridge))
 
-	// Serve via stdio
 	log.Printf("Starting hierarchical MCP proxy (stdio server)")
-	return server.ServeStdio(mcpServer)
+	return server.NewStdioServer(mcpServer).Listen(ctx, bridge, bridge)
 }
 
 // StartHTTPServer starts the HTTP server with the given configuration
@@ -293,9 +253,15 @@ func StartHTTPServer(cfg *config.Config) error {
 	registry := hierarchy.NewServerRegistry(cfg.McpServers)
 	defer registry.Close()
 
-	// Create ONE MCP server with 2 meta-tools
+	// Add servers declared in hierarchy mcp_server blocks (conflicts are logged)
+	serverConfigs, _ := h.ServerConfigs(cfg.McpProxy.Options, cfg.ExpandEnv)
+	registry.MergeServerConfigs(serverConfigs)
+
+	// Create ONE MCP server exposing the hierarchy meta-tools
 	serverOpts := []server.ServerOption{
 		server.WithResourceCapabilities(true, true),
+		server.WithToolCapabilities(true),
+		server.WithPromptCapabilities(true),
 		server.WithRecovery(),
 	}
 
@@ -309,99 +275,23 @@ func StartHTTPServer(cfg *config.Config) error {
 		serverOpts...,
 	)
 
-	// Register get_tools_in_category meta-tool
-	// Build description from root overview
-	description := "You have MCP tools hidden within categories. You MUST use get_tools_in_category to learn more about what available tools you have within these categories. Returns children categories, and tools at the specified path. Call initially with an empty string to get root categories."
-
-	// Get root node and use its overview
-	if rootNode := h.GetRootNode(); rootNode != nil && rootNode.Overview != "" {
-		description += fmt.Sprintf("\n\n%s", rootNode.Overview)
-	}
+	// Register the meta-tools (get_tools_in_category, execute_tool, execute_tools, fetch_result_page, search_tools, describe_tool)
+	metaTools := registerMetaTools(mcpServer, cfg, h, registry)
 
-	getToolsInCategoryTool := mcp.Tool{
-		Name:        "get_tools_in_category",
-		Description: description,
-		InputSchema: mcp.ToolInputSchema{
-			Type: "object",
-			Properties: map[string]interface{}{
-				"path": map[string]interface{}{
-					"type":        "string",
-					"description": "Category path using dot notation (e.g., 'coding_tools' or 'coding_tools.serena.search'). Use empty string or '/' for root.",
-				},
-			},
-			Required: []string{"path"},
-		},
-	}
+	// Register pinnedTools directly, next to the meta-tools (hybrid mode), plus the most used tools
+	pinned := registerPinnedTools(ctx, mcpServer, cfg, h, registry, metaTools)
+	defer pinned.saveUsage()
+	log.Printf("Tokens: %s", h.TokenSummary())
 
-	mcpServer.AddTool(getToolsInCategoryTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		path := ""
-		if request.Params.Arguments != nil {
-			if argsMap, ok := request.Params.Arguments.(map[string]interface{}); ok {
-				if pathVal, ok := argsMap["path"].(string); ok {
-					path = pathVal
-				}
-			}
-		}
+	// Proxy downstream prompts and resources under namespaced names
+	registerDownstreamCapabilities(mcpServer, registry)
+	startDownstreamDiscovery(ctx, cfg, mcpServer, registry)
 
-		response, err := h.HandleGetToolsInCategory(path)
-		if err != nil {
-			return nil, err
-		}
+	// The HTTP transports can't send elicitation requests, confirmTools uses its fallback
+	h.SetConfirmationPolicy(newConfirmationPolicy(cfg.McpProxy.Options, nil))
 
-		jsonBytes, err := json.MarshalIndent(response, "", "  ")
-		if err != nil {
-			return nil, err
-		}
-
-		return &mcp.CallToolResult{
-			Content: []mcp.Content{
-				mcp.NewTextContent(string(jsonBytes)),
-			},
-		}, nil
-	})
-
-	// Register execute_tool meta-tool
-	executeToolTool := mcp.Tool{
-		Name:        "execute_tool",
-		Description: "Execute a tool by its full path. Automatically proxies the request to the appropriate MCP server.",
-		InputSchema: mcp.ToolInputSchema{
-			Type: "object",
-			Properties: map[string]interface{}{
-				"tool_path": map[string]interface{}{
-					"type":        "string",
-					"description": "Full tool path using dot notation (e.g., 'coding_tools.serena.search.search_symbol') or just tool name if unique",
-				},
-				"arguments": map[string]interface{}{
-					"type":                 "object",
-					"description":          "Arguments to pass to the tool",
-					"additionalProperties": true,
-				},
-			},
-			Required: []string{"tool_path", "arguments"},
-		},
-	}
-
-	mcpServer.AddTool(executeToolTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		toolPath := ""
-		arguments := make(map[string]interface{})
-
-		if request.Params.Arguments != nil {
-			if argsMap, ok := request.Params.Arguments.(map[string]interface{}); ok {
-				if pathVal, ok := argsMap["tool_path"].(string); ok {
-					toolPath = pathVal
-				}
-				if argsVal, ok := argsMap["arguments"].(map[string]interface{}); ok {
-					arguments = argsVal
-				}
-			}
-		}
-
-		if toolPath == "" {
-			return nil, fmt.Errorf("tool_path is required")
-		}
-
-		return h.HandleExecuteTool(ctx, registry, toolPath, arguments)
-	})
+	// Hot reload the hierarchy when files under hierarchyPath change
+	startHierarchyWatcher(ctx, cfg, h, registry, metaTools, pinned)
 
 	// Set up HTTP handler (SSE or Streamable)
 	var handler http.Handler
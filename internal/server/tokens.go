@@ -0,0 +1,83 @@
+package server
+
+import (
+	"context"
+	"log"
+	"slices"
+
+	"github.com/IAMSamuelRodda/mcp-proxy/internal/config"
+	"github.com/IAMSamuelRodda/mcp-proxy/internal/hierarchy"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// configureTokenAccounting applies the charsPerToken and listingMaxTokens options
+func configureTokenAccounting(options *config.OptionsV2, h *hierarchy.Hierarchy) {
+	if options == nil {
+		return
+	}
+	if options.CharsPerToken.OrElse(0) > 0 {
+		h.SetTokenEstimator(hierarchy.CharsPerTokenEstimator(options.CharsPerToken.OrElse(hierarchy.DefaultCharsPerToken)))
+	}
+	h.SetListingBudget(options.ListingMaxTokens.OrElse(0))
+}
+
+// metaToolSet registers the meta-tools and records their names, which pinned tools never take
+type metaToolSet struct {
+	mcpServer *server.MCPServer
+	h         *hierarchy.Hierarchy
+	names     []string
+}
+
+// addMetaTool registers a meta-tool and counts its definition in the token tally
+// Re-registering a tool, e.g. after a reload, replaces it under the same name
+func (m *metaToolSet) addMetaTool(tool mcp.Tool, handler server.ToolHandlerFunc) {
+	m.h.Tokens().SetDefinition(tool.Name, m.h.EstimateTokens(tool))
+	m.mcpServer.AddTool(tool, handler)
+	if !slices.Contains(m.names, tool.Name) {
+		m.names = append(m.names, tool.Name)
+	}
+}
+
+// countResponseTokens wraps a discovery meta-tool so its responses are counted
+// and the running tally is logged against the cost of exposing every tool
+func countResponseTokens(h *hierarchy.Hierarchy, name string, handler server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		result, err := handler(ctx, request)
+		if err != nil || result == nil {
+			return result, err
+		}
+
+		tokens := resultTokens(h, result)
+		h.Tokens().AddResponse(tokens)
+		log.Printf("Tokens: %s response ~%d, %s", name, tokens, h.TokenSummary())
+		return result, nil
+	}
+}
+
+// countToolOutput wraps a tool that runs downstream tools, so the tool output is
+// tallied apart from the discovery responses that make up the proxy's cost
+func countToolOutput(h *hierarchy.Hierarchy, name string, handler server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		result, err := handler(ctx, request)
+		if err != nil || result == nil {
+			return result, err
+		}
+
+		tokens := resultTokens(h, result)
+		h.Tokens().AddToolOutput(tokens)
+		log.Printf("Tokens: %s output ~%d, %s", name, tokens, h.TokenSummary())
+		return result, nil
+	}
+}
+
+// resultTokens estimates the tokens of the text content of a tool result
+func resultTokens(h *hierarchy.Hierarchy, result *mcp.CallToolResult) int {
+	tokens := 0
+	for _, content := range result.Content {
+		if text, ok := content.(mcp.TextContent); ok {
+			tokens += h.EstimateTokens(text.Text)
+		}
+	}
+	return tokens
+}
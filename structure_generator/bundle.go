@@ -0,0 +1,271 @@
+package structure_generator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// A bundle holds a whole hierarchy in one JSON or YAML file. Each category has the
+// fields of its node file (overview, tools, mcp_server, aliases, redirects) plus its
+// subcategories under "categories". Nodes are kept as generic maps so hand-written
+// fields the generator doesn't know about survive a round trip.
+
+// ExportBundle writes the hierarchy in structureDir to a single bundle file
+// The format follows the extension of bundlePath: .yaml/.yml for YAML, otherwise JSON
+func ExportBundle(structureDir, bundlePath string) error {
+	root, err := readNodeMap(filepath.Join(structureDir, "root.json"))
+	if err != nil {
+		return fmt.Errorf("failed to read root.json: %w", err)
+	}
+	if err := exportCategory(structureDir, root); err != nil {
+		return err
+	}
+	return writeBundle(root, bundlePath)
+}
+
+// exportCategory adds the tools and subcategories stored in dir to category
+// Flat leaf files (dir/tool.json holding just that tool) become tools of the category
+func exportCategory(dir string, category map[string]interface{}) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to read directory: %w", err)
+	}
+	dirName := filepath.Base(dir)
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() {
+			child, err := readNodeMap(filepath.Join(dir, name, name+".json"))
+			if os.IsNotExist(err) {
+				child = map[string]interface{}{}
+			} else if err != nil {
+				return fmt.Errorf("failed to read %s: %w", filepath.Join(dir, name, name+".json"), err)
+			}
+			if err := exportCategory(filepath.Join(dir, name), child); err != nil {
+				return err
+			}
+			if err := addEntry(category, "categories", name, child); err != nil {
+				return fmt.Errorf("%s: %w", dir, err)
+			}
+			continue
+		}
+
+		if !strings.HasSuffix(name, ".json") || name == "root.json" || name == dirName+".json" {
+			continue // Not a node, or the node of dir itself
+		}
+		nodeName := strings.TrimSuffix(name, ".json")
+		node, err := readNodeMap(filepath.Join(dir, name))
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", filepath.Join(dir, name), err)
+		}
+
+		toolDef, isLeaf := flatLeafTool(node, nodeName)
+		if !isLeaf {
+			// Anything more than a single tool keeps its own category
+			if err := addEntry(category, "categories", nodeName, node); err != nil {
+				return fmt.Errorf("%s: %w", dir, err)
+			}
+			continue
+		}
+
+		// The leaf node's aliases are old paths of the tool, and its redirects are
+		// relative to the leaf, which becomes nodeName inside the category
+		if aliases, ok := node["aliases"].([]interface{}); ok {
+			existing, _ := toolDef["aliases"].([]interface{})
+			toolDef["aliases"] = append(existing, aliases...)
+		}
+		if redirects, ok := node["redirects"].(map[string]interface{}); ok {
+			for oldPath, newPath := range redirects {
+				if err := addEntry(category, "redirects", nodeName+"."+oldPath, newPath); err != nil {
+					return fmt.Errorf("%s: %w", dir, err)
+				}
+			}
+		}
+		if err := addEntry(category, "tools", nodeName, toolDef); err != nil {
+			return fmt.Errorf("%s: %w", dir, err)
+		}
+	}
+	return nil
+}
+
+// flatLeafTool returns the tool of a flat leaf node: a node holding only a tool named
+// after its file, plus optional aliases and redirects
+func flatLeafTool(node map[string]interface{}, nodeName string) (map[string]interface{}, bool) {
+	for key := range node {
+		if key != "tools" && key != "aliases" && key != "redirects" {
+			return nil, false
+		}
+	}
+	tools, _ := node["tools"].(map[string]interface{})
+	if len(tools) != 1 {
+		return nil, false
+	}
+	toolDef, ok := tools[nodeName].(map[string]interface{})
+	return toolDef, ok
+}
+
+// addEntry sets category[field][key], failing if the key is already taken
+func addEntry(category map[string]interface{}, field, key string, value interface{}) error {
+	entries, ok := category[field].(map[string]interface{})
+	if !ok {
+		entries = map[string]interface{}{}
+		category[field] = entries
+	}
+	if _, exists := entries[key]; exists {
+		return fmt.Errorf("duplicate %s entry %q", strings.TrimSuffix(field, "s"), key)
+	}
+	entries[key] = value
+	return nil
+}
+
+// ImportBundle writes a bundle file out as a hierarchy directory: one nested node file
+// per category and one flat leaf file per tool. outputDir must be empty or not exist,
+// so files of an older layout can't mix with the imported tree
+func ImportBundle(bundlePath, outputDir string) error {
+	root, err := readBundle(bundlePath)
+	if err != nil {
+		return err
+	}
+
+	if entries, err := os.ReadDir(outputDir); err == nil && len(entries) > 0 {
+		return fmt.Errorf("output directory %s is not empty", outputDir)
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	// Root tools stay in root.json, every other category gets its own directory
+	categories, _ := root["categories"].(map[string]interface{})
+	delete(root, "categories")
+	if err := writeJSONFile(root, filepath.Join(outputDir, "root.json")); err != nil {
+		return err
+	}
+	return importCategories(outputDir, categories)
+}
+
+// importCategories writes each category below dir, recursively
+func importCategories(dir string, categories map[string]interface{}) error {
+	for _, name := range sortedKeys(categories) {
+		category, ok := categories[name].(map[string]interface{})
+		if !ok {
+			category = map[string]interface{}{}
+		}
+		if name == "" || strings.ContainsAny(name, "./\\") {
+			return fmt.Errorf("invalid category name %q", name)
+		}
+
+		categoryDir := filepath.Join(dir, name)
+		if err := os.MkdirAll(categoryDir, 0755); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+
+		subcategories, _ := category["categories"].(map[string]interface{})
+		tools, _ := category["tools"].(map[string]interface{})
+		node := make(map[string]interface{}, len(category))
+		for key, value := range category {
+			if key != "categories" && key != "tools" {
+				node[key] = value
+			}
+		}
+
+		// Tools get flat leaf files, except where the file name is taken by the
+		// category's own node file or a subcategory; those stay in the node file
+		nodeTools := map[string]interface{}{}
+		for _, toolName := range sortedKeys(tools) {
+			if _, clash := subcategories[toolName]; clash || toolName == name {
+				nodeTools[toolName] = tools[toolName]
+				continue
+			}
+			leaf := map[string]interface{}{"tools": map[string]interface{}{toolName: tools[toolName]}}
+			if err := writeJSONFile(leaf, filepath.Join(categoryDir, toolName+".json")); err != nil {
+				return err
+			}
+		}
+		if len(nodeTools) > 0 {
+			node["tools"] = nodeTools
+		}
+		if err := writeJSONFile(node, filepath.Join(categoryDir, name+".json")); err != nil {
+			return err
+		}
+
+		if err := importCategories(categoryDir, subcategories); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readNodeMap reads a node file as a generic map
+func readNodeMap(path string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	node := map[string]interface{}{}
+	if err := json.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// readBundle reads a JSON or YAML bundle file as a generic map
+func readBundle(bundlePath string) (map[string]interface{}, error) {
+	data, err := os.ReadFile(bundlePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundle: %w", err)
+	}
+	if isYAMLPath(bundlePath) {
+		// Round-trip through JSON so YAML bundles produce the same values as JSON ones
+		var doc interface{}
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("failed to parse bundle %s: %w", bundlePath, err)
+		}
+		if data, err = json.Marshal(doc); err != nil {
+			return nil, fmt.Errorf("failed to parse bundle %s: %w", bundlePath, err)
+		}
+	}
+	root := map[string]interface{}{}
+	if err := json.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse bundle %s: %w", bundlePath, err)
+	}
+	return root, nil
+}
+
+// writeBundle writes a bundle as YAML or JSON, depending on the file extension
+func writeBundle(root map[string]interface{}, bundlePath string) error {
+	if !isYAMLPath(bundlePath) {
+		return writeJSONFile(root, bundlePath)
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(root); err != nil {
+		return fmt.Errorf("failed to encode YAML: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return fmt.Errorf("failed to encode YAML: %w", err)
+	}
+	return os.WriteFile(bundlePath, buf.Bytes(), 0644)
+}
+
+func isYAMLPath(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,85 @@
+package structure_generator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestFiles writes files relative to dir, creating directories as needed
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+}
+
+func TestBundleRoundTrip(t *testing.T) {
+	tmp := t.TempDir()
+	sourceDir := filepath.Join(tmp, "source")
+	writeTestFiles(t, sourceDir, map[string]string{
+		"root.json": `{"overview": "All tools", "maintainer": "platform team"}`,
+		"github/github.json": `{
+			"overview": "GitHub tools",
+			"mcp_server": {"command": "/usr/bin/github-mcp"},
+			"tools": {
+				"search": {"description": "Search everything", "maps_to": "search"},
+				"github": {"description": "Named after its category", "maps_to": "github"}
+			}
+		}`,
+		"github/create_issue.json": `{
+			"tools": {"create_issue": {"description": "Create an issue", "maps_to": "create_issue", "aliases": ["issues.create"]}},
+			"aliases": ["new_issue"],
+			"redirects": {"open": "github.create_issue"}
+		}`,
+		"github/search/search.json": `{"overview": "Search tools"}`,
+		"github/search/code.json":   `{"tools": {"code": {"description": "Search code", "maps_to": "search_code"}}}`,
+	})
+
+	firstBundle := filepath.Join(tmp, "first.yaml")
+	require.NoError(t, ExportBundle(sourceDir, firstBundle))
+	importedDir := filepath.Join(tmp, "imported")
+	require.NoError(t, ImportBundle(firstBundle, importedDir))
+	secondBundle := filepath.Join(tmp, "second.json")
+	require.NoError(t, ExportBundle(importedDir, secondBundle))
+
+	first, err := readBundle(firstBundle)
+	require.NoError(t, err)
+	second, err := readBundle(secondBundle)
+	require.NoError(t, err)
+	assert.Equal(t, first, second)
+
+	// Hand-written fields survive
+	assert.Equal(t, "platform team", first["maintainer"])
+	github := first["categories"].(map[string]interface{})["github"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"command": "/usr/bin/github-mcp"}, github["mcp_server"])
+
+	// The leaf's aliases join the tool's, and its redirects are rewritten relative to the category
+	tools := github["tools"].(map[string]interface{})
+	createIssue := tools["create_issue"].(map[string]interface{})
+	assert.Equal(t, []interface{}{"issues.create", "new_issue"}, createIssue["aliases"])
+	assert.Equal(t, map[string]interface{}{"create_issue.open": "github.create_issue"}, github["redirects"])
+
+	// Tools named after the category or a subcategory stay in the node file, the rest get leaf files
+	node, err := readNodeMap(filepath.Join(importedDir, "github", "github.json"))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"search", "github"}, sortedKeys(node["tools"].(map[string]interface{})))
+	assert.FileExists(t, filepath.Join(importedDir, "github", "create_issue.json"))
+	assert.FileExists(t, filepath.Join(importedDir, "github", "search", "code.json"))
+	assert.NoFileExists(t, filepath.Join(importedDir, "github", "search.json"))
+}
+
+func TestImportBundleRefusesNonEmptyDirectory(t *testing.T) {
+	tmp := t.TempDir()
+	bundlePath := filepath.Join(tmp, "bundle.json")
+	writeTestFiles(t, tmp, map[string]string{
+		"bundle.json":    `{"overview": "All tools"}`,
+		"out/stale.json": `{}`,
+	})
+	assert.ErrorContains(t, ImportBundle(bundlePath, filepath.Join(tmp, "out")), "not empty")
+}
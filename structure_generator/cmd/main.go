@@ -47,8 +47,32 @@ func main() {
 	outputDir := flag.String("output", "./structure", "Output directory for generated structure")
 	configPath := flag.String("config", "", "Path to MCP server config JSON (to fetch tools from live servers)")
 	regenerateRoot := flag.Bool("regenerate", false, "Regenerate hierarchy from existing structure (preserves manual edits)")
+	exportBundle := flag.String("export-bundle", "", "Export the structure in -output to a single JSON or YAML bundle file")
+	importBundle := flag.String("import-bundle", "", "Import a JSON or YAML bundle file into the (empty) -output directory")
 	flag.Parse()
 
+	// Mode 4: Export to a single-file bundle
+	if *exportBundle != "" {
+		log.Printf("Exporting %s to bundle: %s", *outputDir, *exportBundle)
+		if err := generator.ExportBundle(*outputDir, *exportBundle); err != nil {
+			log.Fatalf("Failed to export bundle: %v", err)
+		}
+		fmt.Printf("\n✓ Successfully exported bundle!\n")
+		fmt.Printf("  Location: %s\n", *exportBundle)
+		os.Exit(0)
+	}
+
+	// Mode 5: Import a single-file bundle
+	if *importBundle != "" {
+		log.Printf("Importing bundle %s into: %s", *importBundle, *outputDir)
+		if err := generator.ImportBundle(*importBundle, *outputDir); err != nil {
+			log.Fatalf("Failed to import bundle: %v", err)
+		}
+		fmt.Printf("\n✓ Successfully imported bundle!\n")
+		fmt.Printf("  Location: %s\n", *outputDir)
+		os.Exit(0)
+	}
+
 	// Mode 0: Regenerate hierarchy
 	if *regenerateRoot {
 		log.Printf("Regenerating hierarchy (preserves manual edits) in: %s", *outputDir)
@@ -99,7 +123,9 @@ func main() {
 		log.Fatal("Usage:\n" +
 			"  Mode 1 (fetch from live servers):  go run cmd/main.go -config <config.json>\n" +
 			"  Mode 2 (use pre-fetched data):     go run cmd/main.go -input <file1.json> -input <file2.json>\n" +
-			"  Mode 3 (regenerate hierarchy):     go run cmd/main.go -regenerate -output <structure_dir>\n\n" +
+			"  Mode 3 (regenerate hierarchy):     go run cmd/main.go -regenerate -output <structure_dir>\n" +
+			"  Mode 4 (export bundle):            go run cmd/main.go -output <structure_dir> -export-bundle <hierarchy.yaml>\n" +
+			"  Mode 5 (import bundle):            go run cmd/main.go -import-bundle <hierarchy.yaml> -output <empty_dir>\n\n" +
 			"Examples:\n" +
 			"  go run cmd/main.go -config tests/test_data/test_config.json\n" +
 			"  go run cmd/main.go -input tests/test_data/github_tools.json -input tests/test_data/everything_tools.json\n" +
@@ -101,8 +101,17 @@ func Regenerate(outputDir string) error {
 		Tools:    nil, // Root doesn't have direct tools
 	}
 
-	// Write root.json
+	// Keep hand-written redirects from the previous root.json
 	rootPath := filepath.Join(outputDir, "root.json")
+	if existingData, err := os.ReadFile(rootPath); err == nil {
+		var existingNode ToolNode
+		if json.Unmarshal(existingData, &existingNode) == nil {
+			rootNode.Aliases = existingNode.Aliases
+			rootNode.Redirects = existingNode.Redirects
+		}
+	}
+
+	// Write root.json
 	return writeNodeToJSON(rootNode, rootPath)
 }
 
@@ -259,6 +268,15 @@ func RegenerateDirectory(dirPath string, nodeName string) error {
 		Tools:    nil, // Branch nodes don't have tools
 	}
 
+	// Keep hand-written aliases and redirects
+	if existingData != nil {
+		var existingNode ToolNode
+		if json.Unmarshal(existingData, &existingNode) == nil {
+			node.Aliases = existingNode.Aliases
+			node.Redirects = existingNode.Redirects
+		}
+	}
+
 	// Write the updated JSON file
 	return writeNodeToJSON(node, nodeJSONPath)
 }
@@ -451,6 +469,12 @@ func generateToolFile(tool Tool, parentDir string, serverName string) error {
 
 // writeNodeToJSON writes a ToolNode to a JSON file with pretty formatting
 func writeNodeToJSON(node ToolNode, path string) error {
+	// Encode with pointer to invoke MarshalJSON method
+	return writeJSONFile(&node, path)
+}
+
+// writeJSONFile writes a value to a JSON file with pretty formatting
+func writeJSONFile(v interface{}, path string) error {
 	// Create file
 	file, err := os.Create(path)
 	if err != nil {
@@ -463,8 +487,7 @@ func writeNodeToJSON(node ToolNode, path string) error {
 	encoder.SetEscapeHTML(false)
 	encoder.SetIndent("", "  ")
 
-	// Encode with pointer to invoke MarshalJSON method
-	if err := encoder.Encode(&node); err != nil {
+	if err := encoder.Encode(v); err != nil {
 		return fmt.Errorf("failed to encode JSON: %w", err)
 	}
 
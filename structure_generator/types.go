@@ -32,6 +32,11 @@ type ToolNode struct {
 	// Tools maps tool names to their full definitions
 	// Only present for leaf nodes
 	Tools map[string]ToolDefinition `json:"tools,omitempty"`
+
+	// Aliases and Redirects keep old paths working after a reorganization
+	// They are hand-written and preserved across regeneration
+	Aliases   []string          `json:"aliases,omitempty"`
+	Redirects map[string]string `json:"redirects,omitempty"`
 }
 
 // ToolDefinition is the detailed definition of a single tool for output
@@ -43,6 +48,9 @@ type ToolDefinition struct {
 	InputSchema  map[string]interface{} `json:"inputSchema,omitempty"`
 	OutputSchema map[string]interface{} `json:"outputSchema,omitempty"`
 	Annotations  map[string]interface{} `json:"annotations,omitempty"`
+	Aliases      []string               `json:"aliases,omitempty"`      // Old tool paths that still resolve to this tool
+	FixedArgs    map[string]interface{} `json:"fixed_args,omitempty"`   // Always sent, hidden from the model
+	DefaultArgs  map[string]interface{} `json:"default_args,omitempty"` // Sent when the model omits the argument
 }
 
 // DomainCategory represents a top-level categorization
@@ -143,6 +151,13 @@ func (n *ToolNode) MarshalJSON() ([]byte, error) {
 		output["tools"] = n.Tools
 	}
 
+	if len(n.Aliases) > 0 {
+		output["aliases"] = n.Aliases
+	}
+	if len(n.Redirects) > 0 {
+		output["redirects"] = n.Redirects
+	}
+
 	// Return un-indented JSON - let the encoder handle indentation
 	return json.Marshal(output)
 }